@@ -3,37 +3,207 @@ package main
 import (
 	"bytes"
 	"compress/gzip"
+	"database/sql"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"strings"
+	"time"
 
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/blocklist"
+	"github.com/VadimFilimonov/urlshortener/internal/clicks"
 	"github.com/VadimFilimonov/urlshortener/internal/config"
+	"github.com/VadimFilimonov/urlshortener/internal/destpolicy"
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
 	"github.com/VadimFilimonov/urlshortener/internal/handler"
+	"github.com/VadimFilimonov/urlshortener/internal/ratelimit"
 	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	utils "github.com/VadimFilimonov/urlshortener/internal/utils/generateid"
+	"github.com/VadimFilimonov/urlshortener/internal/utm"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 )
 
+const (
+	reloadInterval = 10 * time.Second
+	// A visitor may try 5 passwords of a link or an account in a row and
+	// then one every 3 minutes.
+	passwordAttempts       = 5
+	passwordAttemptsPeriod = 15 * time.Minute
+	// Registering and logging in hash a password with argon2, which takes
+	// time and memory, so a client IP may do it 20 times in a row and then
+	// once every 45 seconds, whatever the email.
+	accountRequests       = 20
+	accountRequestsPeriod = 15 * time.Minute
+)
+
 func main() {
 	config := config.New()
 	config.Parse()
 
+	if config.BlocklistPath != "" {
+		list, err := blocklist.Load(config.BlocklistPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		utils.SetBlocklist(list)
+	}
+
 	data, err := storage.GetStorage(config)
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	validator := validation.New(config.BaseURL, config.AllowedSchemes)
+
+	var policy *destpolicy.Policy
+	if config.DestinationPolicyPath != "" {
+		policy, err = destpolicy.Load(config.DestinationPolicyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		policy.Watch(reloadInterval)
+	}
+
+	presets := utm.Presets{}
+	if config.CampaignsPath != "" {
+		presets, err = utm.LoadPresets(config.CampaignsPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var geo *geoip.DB
+	if config.GeoIPPath != "" {
+		geo, err = geoip.Load(config.GeoIPPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		geo.Watch(reloadInterval)
+	}
+
+	var placeholder []byte
+	if config.PlaceholderPath != "" {
+		placeholder, err = os.ReadFile(config.PlaceholderPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Links outlive a restart with persistent storage, and so must the
+	// cookies of their users. Without configured secrets one is kept next to
+	// the links file, a server with only a database gets a random one.
+	if len(config.CookieSecrets) == 0 {
+		var secret string
+
+		if config.FileStoragePath != "" {
+			secret, err = auth.LoadSecret(config.FileStoragePath + ".secret")
+		} else {
+			if config.DatabaseDNS != "" {
+				log.Println("WARNING: no cookie secrets configured, users lose access to their links on restart; set COOKIE_SECRETS")
+			}
+			secret, err = auth.NewSecret()
+		}
+
+		if err != nil {
+			log.Fatal(err)
+		}
+		config.CookieSecrets = []string{secret}
+	}
+
+	signer, err := auth.NewSigner(config.CookieSecrets)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var tokens *auth.TokenVerifier
+	if config.JWTSecret != "" || config.JWTPublicKeyPath != "" {
+		tokens, err = auth.LoadTokenVerifier(config.JWTSecret, config.JWTPublicKeyPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	authenticator := auth.New(signer, tokens, data)
+
+	var rateLimits *sql.DB
+	if config.RateLimitShared {
+		if config.DatabaseDNS == "" {
+			log.Fatal("shared rate limits need a database")
+		}
+		rateLimits, err = storage.InitDB(config.DatabaseDNS)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	createLimit, err := newRateLimit(rateLimits, "create", config.RateLimitCreate)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	redirectLimit, err := newRateLimit(rateLimits, "redirect", config.RateLimitRedirect)
+	if err != nil {
+		log.Fatal(err)
+	}
+
 	r := chi.NewRouter()
+	r.Use(ratelimit.RealIP(config.TrustedProxyHeader))
 	r.Use(decompressMiddleware)
 	r.Use(middleware.Compress(5))
-	r.Get("/{shortenURL}", handler.NewGet(data, config.BaseURL))
-	r.Post("/", handler.NewPost(data, config.BaseURL))
-	r.Post("/api/shorten", handler.NewShorten(data, config.BaseURL))
-	r.Post("/api/shorten/batch", handler.NewShortenBatch(data, config.BaseURL))
-	r.Get("/api/user/urls", handler.NewGetUserUrls(data, config.BaseURL))
-	r.Delete("/api/user/urls", handler.NewDeleteUserUrls(data))
+	recorder := clicks.NewRecorder(data)
+
+	attempts := ratelimit.New(passwordAttempts, passwordAttemptsPeriod)
+	loginAttempts := ratelimit.New(passwordAttempts, passwordAttemptsPeriod)
+
+	get := handler.NewGet(data, config.BaseURL, config.DefaultRedirectType, recorder, geo, attempts, placeholder)
+	r.Group(func(r chi.Router) {
+		r.Use(ratelimit.Middleware(redirectLimit, ratelimit.ByIP))
+		r.Get("/{shortenURL}", get)
+		r.Get("/{shortenURL}/*", get)
+		r.Post("/{shortenURL}", get)
+		r.Post("/{shortenURL}/*", get)
+	})
+	r.Get("/api/qr/{shortenURL}", handler.NewGetQR(data, config.BaseURL))
 	r.Get("/ping", handler.NewPing(config.DatabaseDNS))
+	r.Group(func(r chi.Router) {
+		r.Use(ratelimit.Middleware(ratelimit.New(accountRequests, accountRequestsPeriod), ratelimit.ByIP))
+		r.Post("/api/auth/register", handler.NewRegister(data, signer))
+		r.Post("/api/auth/login", handler.NewLogin(data, signer, loginAttempts))
+	})
+	r.Post("/api/auth/logout", handler.NewLogout())
+
+	shorten := auth.RequireScope(auth.ScopeShorten)
+	read := auth.RequireScope(auth.ScopeRead)
+	edit := auth.RequireScope(auth.ScopeEdit)
+	remove := auth.RequireScope(auth.ScopeDelete)
+	keys := auth.RequireScope(auth.ScopeKeys)
+
+	r.Group(func(r chi.Router) {
+		r.Use(authenticator.Middleware, shorten, ratelimit.Middleware(createLimit, ratelimit.ByUser))
+		r.Post("/", handler.NewPost(data, config.BaseURL, validator, policy, presets))
+		r.Post("/api/shorten", handler.NewShorten(data, config.BaseURL, validator, policy, presets))
+		r.Post("/api/shorten/batch", handler.NewShortenBatch(data, config.BaseURL, validator, policy, presets))
+	})
+
+	r.Group(func(r chi.Router) {
+		r.Use(authenticator.Middleware)
+		r.With(read).Get("/api/user/urls", handler.NewGetUserUrls(data, config.BaseURL))
+		r.With(remove).Delete("/api/user/urls", handler.NewDeleteUserUrls(data))
+		r.With(read).Get("/api/user/urls/{id}/stats", handler.NewGetStats(data))
+		r.With(read).Get("/api/user/urls/{id}/clicks", handler.NewGetClicks(data))
+		r.With(edit).Patch("/api/user/urls/{id}", handler.NewUpdateUserURL(data, config.BaseURL, validator, policy, presets))
+		r.With(read).Get("/api/user/urls/{id}/revisions", handler.NewGetRevisions(data))
+		r.With(edit).Post("/api/user/urls/{id}/revisions/{revision}/rollback", handler.NewRollback(data, config.BaseURL, validator, policy))
+		r.With(read).Get("/api/user/tags", handler.NewGetUserTags(data))
+		r.With(read).Get("/api/user/folders", handler.NewGetUserFolders(data))
+		r.With(keys).Post("/api/user/keys", handler.NewCreateAPIKey(data))
+		r.With(keys).Get("/api/user/keys", handler.NewGetAPIKeys(data))
+		r.With(keys).Delete("/api/user/keys/{id}", handler.NewRevokeAPIKey(data))
+	})
+
 	err = http.ListenAndServe(config.ServerAddress, r)
 
 	if err != nil {
@@ -41,6 +211,21 @@ func main() {
 	}
 }
 
+// newRateLimit returns the store of a rate limit, in the database if db is
+// set, or nil if the limit is off.
+func newRateLimit(db *sql.DB, name, value string) (ratelimit.Store, error) {
+	limit, period, err := ratelimit.ParseRate(value)
+	if err != nil || limit == 0 {
+		return nil, err
+	}
+
+	if db != nil {
+		return ratelimit.NewPostgres(db, name, limit, period), nil
+	}
+
+	return ratelimit.New(limit, period), nil
+}
+
 func decompressMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !strings.Contains(r.Header.Get("Content-Encoding"), "gzip") {
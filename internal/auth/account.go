@@ -0,0 +1,101 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Parameters of argon2id for account passwords, the ones RFC 9106
+// recommends for memory-constrained environments.
+const (
+	argon2Time    = 3
+	argon2Memory  = 64 * 1024
+	argon2Threads = 4
+	argon2KeySize = 32
+	argon2Salt    = 16
+)
+
+// hashing limits how many passwords are hashed at once. Every hash takes
+// argon2Memory KiB, so without a limit parallel logins could exhaust the
+// memory of the server.
+var hashing = make(chan struct{}, runtime.NumCPU())
+
+// dummyHash is checked against when there is no account with the email, so
+// a login takes as long whether the account exists or not.
+var dummyHash, _ = HashPassword("")
+
+// HashPassword returns the argon2id hash of an account password in the
+// $argon2id$v=19$m=...,t=...,p=...$salt$key format.
+func HashPassword(password string) (string, error) {
+	salt := make([]byte, argon2Salt)
+
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	hashing <- struct{}{}
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeySize)
+	<-hashing
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s", argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// CheckPassword reports whether password matches hash. The parameters are
+// read from hash, so hashes made before they were raised still work. An
+// empty hash stands for a missing account and never matches.
+func CheckPassword(hash, password string) bool {
+	if hash == "" {
+		checkPassword(dummyHash, password)
+		return false
+	}
+
+	matched, err := checkPassword(hash, password)
+
+	return err == nil && matched
+}
+
+func checkPassword(hash, password string) (bool, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return false, errors.New("unsupported password hash")
+	}
+
+	var version int
+	var memory, time uint32
+	var threads uint8
+
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false, errors.New("unsupported argon2 version")
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil {
+		return false, err
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, err
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, err
+	}
+
+	hashing <- struct{}{}
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(key)))
+	<-hashing
+
+	return subtle.ConstantTimeCompare(key, actual) == 1, nil
+}
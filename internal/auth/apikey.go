@@ -0,0 +1,105 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+// APIKeyHeader is the request header machine clients send their key in.
+const APIKeyHeader = "X-API-Key"
+
+// Scopes limit what an API key may do. Requests authenticated otherwise may
+// do everything.
+const (
+	ScopeShorten = "shorten"
+	ScopeRead    = "read"
+	ScopeEdit    = "edit"
+	ScopeDelete  = "delete"
+	// ScopeKeys guards managing API keys. It is not in Scopes and cannot be
+	// granted, so a key cannot mint other keys.
+	ScopeKeys = "keys"
+)
+
+// Scopes are the scopes an API key can be created with.
+var Scopes = []string{ScopeShorten, ScopeRead, ScopeEdit, ScopeDelete}
+
+const apiKeyPrefix = "usk_"
+
+// KeyStore finds API keys by the hash of the key.
+type KeyStore interface {
+	GetAPIKeyByHash(hash string) (storage.APIKey, error)
+}
+
+// NewAPIKey returns a random key and an ID to refer to it by.
+func NewAPIKey() (key, id string, err error) {
+	secret := make([]byte, 32)
+
+	_, err = rand.Read(secret)
+	if err != nil {
+		return "", "", err
+	}
+
+	id, err = randomString(8)
+	if err != nil {
+		return "", "", err
+	}
+
+	return apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret), id, nil
+}
+
+// HashAPIKey returns the hash an API key is stored as. Keys are random, so
+// unlike passwords they need no salt or slow hash.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// DisplayPrefix returns the start of key shown to tell keys apart.
+func DisplayPrefix(key string) string {
+	return key[:len(apiKeyPrefix)+6]
+}
+
+// ValidateScopes checks that scopes can be granted to an API key.
+func ValidateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return fmt.Errorf("an API key needs at least one of the scopes %v", Scopes)
+	}
+
+	for _, scope := range scopes {
+		if !slices.Contains(Scopes, scope) {
+			return fmt.Errorf("unknown scope %q, expected one of %v", scope, Scopes)
+		}
+	}
+
+	return nil
+}
+
+// HasScope reports whether the request may do what scope allows. Only
+// requests authenticated with an API key are limited.
+func HasScope(ctx context.Context, scope string) bool {
+	identity, _ := ctx.Value(contextKey{}).(identity)
+	return identity.scopes == nil || slices.Contains(identity.scopes, scope)
+}
+
+// RequireScope answers requests lacking scope with 403 Forbidden.
+func RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !HasScope(r.Context(), scope) {
+				http.Error(w, fmt.Sprintf("API key lacks the %s scope", scope), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	signer, err := NewSigner([]string{newSecret})
+	require.NoError(t, err)
+	data := storage.NewMemory(storage.DedupeNone)
+	authenticator := New(signer, nil, data)
+
+	key, id, err := NewAPIKey()
+	require.NoError(t, err)
+	require.NoError(t, data.AddAPIKey(storage.APIKey{ID: id, UserID: "ci", Hash: HashAPIKey(key), Scopes: []string{ScopeShorten}}))
+
+	revokedKey, id, err := NewAPIKey()
+	require.NoError(t, err)
+	revokedAt := time.Now()
+	require.NoError(t, data.AddAPIKey(storage.APIKey{ID: id, UserID: "ci", Hash: HashAPIKey(revokedKey), Scopes: Scopes, RevokedAt: &revokedAt}))
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(UserID(r.Context())))
+	})
+
+	tests := []struct {
+		name       string
+		key        string
+		scope      string
+		statusCode int
+		userID     string
+	}{
+		{name: "Key with the scope", key: key, scope: ScopeShorten, statusCode: http.StatusOK, userID: "ci"},
+		{name: "Key without the scope", key: key, scope: ScopeRead, statusCode: http.StatusForbidden},
+		{name: "Keys cannot manage keys", key: key, scope: ScopeKeys, statusCode: http.StatusForbidden},
+		{name: "Revoked key", key: revokedKey, scope: ScopeShorten, statusCode: http.StatusUnauthorized},
+		{name: "Unknown key", key: key + "x", scope: ScopeShorten, statusCode: http.StatusUnauthorized},
+		{name: "Cookie has every scope", scope: ScopeKeys, statusCode: http.StatusOK, userID: "user1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/shorten", nil)
+			request.AddCookie(&http.Cookie{Name: CookieName, Value: signer.Sign("user1")})
+			if tt.key != "" {
+				request.Header.Set(APIKeyHeader, tt.key)
+			}
+			w := httptest.NewRecorder()
+			authenticator.Middleware(RequireScope(tt.scope)(echo)).ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+
+			if tt.statusCode == http.StatusOK {
+				assert.Equal(t, tt.userID, w.Body.String())
+			}
+
+			if tt.key != "" {
+				assert.Empty(t, result.Cookies())
+			}
+		})
+	}
+}
+
+func TestValidateScopes(t *testing.T) {
+	assert.NoError(t, ValidateScopes([]string{ScopeRead, ScopeShorten}))
+	assert.Error(t, ValidateScopes(nil))
+	assert.Error(t, ValidateScopes([]string{ScopeKeys}))
+	assert.Error(t, ValidateScopes([]string{"admin"}))
+}
@@ -0,0 +1,317 @@
+// Package auth identifies the user behind a request.
+package auth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+// CookieName is the cookie holding the signed user ID.
+const CookieName = "userID"
+
+// CookieLifetime is how long a cookie stays valid. Middleware signs it anew
+// on every response, so it only expires for users who stay away that long.
+const CookieLifetime = 30 * 24 * time.Hour
+
+// MinSecretLength is the minimal length of a cookie secret in bytes.
+const MinSecretLength = 32
+
+// Signer signs user IDs with HMAC-SHA256. The first secret signs, all of
+// them verify, so a secret is rotated by putting a new one first and dropping
+// the old one once the cookies signed with it have been replaced.
+type Signer struct {
+	secrets [][]byte
+}
+
+func NewSigner(secrets []string) (*Signer, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("at least one cookie secret is required")
+	}
+
+	signer := &Signer{}
+
+	for _, secret := range secrets {
+		if len(secret) < MinSecretLength {
+			return nil, fmt.Errorf("cookie secret must be at least %d bytes long", MinSecretLength)
+		}
+		signer.secrets = append(signer.secrets, []byte(secret))
+	}
+
+	return signer, nil
+}
+
+// NewSecret returns a random secret for a server started without one.
+func NewSecret() (string, error) {
+	return randomString(MinSecretLength)
+}
+
+// LoadSecret returns the secret saved in filename, saving a new one there on
+// the first start, so cookies outlive restarts of a server started without
+// configured secrets.
+func LoadSecret(filename string) (string, error) {
+	data, err := os.ReadFile(filename)
+
+	if errors.Is(err, os.ErrNotExist) {
+		secret, err := NewSecret()
+		if err != nil {
+			return "", err
+		}
+
+		return secret, os.WriteFile(filename, []byte(secret+"\n"), 0600)
+	}
+
+	if err != nil {
+		return "", err
+	}
+
+	secret := strings.TrimSpace(string(data))
+	if len(secret) < MinSecretLength {
+		return "", fmt.Errorf("cookie secret in %s must be at least %d bytes long", filename, MinSecretLength)
+	}
+
+	return secret, nil
+}
+
+// Sign returns the cookie value for userID valid for CookieLifetime: the ID,
+// the expiry in Unix seconds and their signature separated by dots.
+func (s *Signer) Sign(userID string) string {
+	return s.signUntil(userID, time.Now().Add(CookieLifetime))
+}
+
+func (s *Signer) signUntil(userID string, expires time.Time) string {
+	payload := userID + "." + strconv.FormatInt(expires.Unix(), 10)
+
+	return payload + "." + sign(s.secrets[0], payload)
+}
+
+// Verify returns the user ID of an unexpired cookie value signed by any of
+// the secrets. Values without an expiry are rejected.
+func (s *Signer) Verify(value string) (userID string, ok bool) {
+	separator := strings.LastIndex(value, ".")
+	if separator <= 0 {
+		return "", false
+	}
+
+	payload, signature := value[:separator], value[separator+1:]
+
+	signed := slices.ContainsFunc(s.secrets, func(secret []byte) bool {
+		return hmac.Equal([]byte(signature), []byte(sign(secret, payload)))
+	})
+	if !signed {
+		return "", false
+	}
+
+	separator = strings.LastIndex(payload, ".")
+	if separator <= 0 {
+		return "", false
+	}
+
+	expires, err := strconv.ParseInt(payload[separator+1:], 10, 64)
+	if err != nil || time.Now().After(time.Unix(expires, 0)) {
+		return "", false
+	}
+
+	return payload[:separator], true
+}
+
+func sign(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// SetCookie makes the client act as userID from now on.
+func (s *Signer) SetCookie(w http.ResponseWriter, userID string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Value:    s.Sign(userID),
+		Path:     "/",
+		MaxAge:   int(CookieLifetime.Seconds()),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// ClearCookie removes the cookie, the next request gets a new user.
+func ClearCookie(w http.ResponseWriter) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     CookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// CookieUserID returns the user of a valid cookie of r.
+func (s *Signer) CookieUserID(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(CookieName)
+	if err != nil {
+		return "", false
+	}
+
+	return s.Verify(cookie.Value)
+}
+
+// NewUserID returns a random ID for a new user.
+func NewUserID() (string, error) {
+	return randomString(16)
+}
+
+func randomString(size int) (string, error) {
+	value := make([]byte, size)
+
+	_, err := rand.Read(value)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(value), nil
+}
+
+type contextKey struct{}
+
+// identity is what Middleware puts into the request context.
+type identity struct {
+	userID string
+	// scopes of the API key the request came with, nil for other requests.
+	scopes []string
+	// issued is set when the request had no user and got a new one.
+	issued bool
+}
+
+func withIdentity(r *http.Request, identity identity) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), contextKey{}, identity))
+}
+
+// UserID returns the user Middleware identified the request with, or an
+// empty string if the request did not go through it.
+func UserID(ctx context.Context) string {
+	identity, _ := ctx.Value(contextKey{}).(identity)
+	return identity.userID
+}
+
+// Identified reports whether the request came with its user, rather than
+// getting a new one from Middleware. Limits per user only hold for those.
+func Identified(ctx context.Context) bool {
+	identity, found := ctx.Value(contextKey{}).(identity)
+	return found && !identity.issued
+}
+
+// Authenticator identifies the user of a request by an API key, a bearer
+// token or the signed cookie.
+type Authenticator struct {
+	signer *Signer
+	tokens *TokenVerifier
+	keys   KeyStore
+}
+
+// New returns an Authenticator accepting cookies signed by signer, tokens
+// verified by tokens, which may be nil to accept no tokens, and API keys
+// from keys.
+func New(signer *Signer, tokens *TokenVerifier, keys KeyStore) *Authenticator {
+	return &Authenticator{signer: signer, tokens: tokens, keys: keys}
+}
+
+// Middleware puts the user ID into the request context.
+//
+// A request with an X-API-Key header is identified by the key and limited to
+// its scopes, an unknown or revoked key is rejected with 401 Unauthorized.
+// Otherwise a request with an Authorization: Bearer header is identified by
+// the token, an invalid or expired token is rejected with 401 Unauthorized
+// rather than falling back to the cookie, so a client with a stale token
+// does not silently act as a new anonymous user. Requests identified by a
+// key or token get no cookie.
+//
+// Requests without a cookie or with one that fails verification get a new
+// user. The cookie is signed anew on every response, so cookies signed with
+// an older secret are replaced as their users come back.
+func (a *Authenticator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get(APIKeyHeader); key != "" {
+			a.serveAPIKey(w, r, next, key)
+			return
+		}
+
+		if token, ok := bearerToken(r); ok {
+			userID, err := a.tokens.Verify(token)
+
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, withIdentity(r, identity{userID: userID}))
+			return
+		}
+
+		userID, ok := a.signer.CookieUserID(r)
+
+		if !ok {
+			var err error
+			userID, err = NewUserID()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		a.signer.SetCookie(w, userID)
+
+		next.ServeHTTP(w, withIdentity(r, identity{userID: userID, issued: !ok}))
+	})
+}
+
+func (a *Authenticator) serveAPIKey(w http.ResponseWriter, r *http.Request, next http.Handler, key string) {
+	if a.keys == nil {
+		http.Error(w, "API keys are not accepted", http.StatusUnauthorized)
+		return
+	}
+
+	apiKey, err := a.keys.GetAPIKeyByHash(HashAPIKey(key))
+
+	if errors.Is(err, storage.ErrAPIKeyNotFound) || (err == nil && apiKey.RevokedAt != nil) {
+		http.Error(w, "invalid API key", http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	scopes := apiKey.Scopes
+	if scopes == nil {
+		scopes = []string{}
+	}
+
+	next.ServeHTTP(w, withIdentity(r, identity{userID: apiKey.UserID, scopes: scopes}))
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	header := r.Header.Get("Authorization")
+	prefix := "Bearer "
+
+	if len(header) <= len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+
+	return strings.TrimSpace(header[len(prefix):]), true
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	oldSecret = strings.Repeat("o", MinSecretLength)
+	newSecret = strings.Repeat("n", MinSecretLength)
+)
+
+func TestSigner(t *testing.T) {
+	_, err := NewSigner(nil)
+	assert.Error(t, err)
+	_, err = NewSigner([]string{"short"})
+	assert.Error(t, err)
+
+	oldSigner, err := NewSigner([]string{oldSecret})
+	require.NoError(t, err)
+	signer, err := NewSigner([]string{newSecret, oldSecret})
+	require.NoError(t, err)
+	otherSigner, err := NewSigner([]string{strings.Repeat("x", MinSecretLength)})
+	require.NoError(t, err)
+
+	value := signer.Sign("user1")
+	tests := []struct {
+		name   string
+		value  string
+		userID string
+		ok     bool
+	}{
+		{name: "Signed", value: value, userID: "user1", ok: true},
+		{name: "Signed with an old secret", value: oldSigner.Sign("user1"), userID: "user1", ok: true},
+		{name: "Unsigned", value: "user1", ok: false},
+		{name: "Other user", value: "user2" + value[strings.Index(value, "."):], ok: false},
+		{name: "Tampered signature", value: value[:len(value)-1] + "A", ok: false},
+		{name: "Unknown secret", value: otherSigner.Sign("user1"), ok: false},
+		{name: "Empty user", value: signer.Sign(""), ok: false},
+		{name: "Expired", value: signer.signUntil("user1", time.Now().Add(-time.Minute)), ok: false},
+		{name: "Without expiry", value: "user1." + sign([]byte(newSecret), "user1"), ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, ok := signer.Verify(tt.value)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.userID, userID)
+		})
+	}
+}
+
+func TestLoadSecret(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "urls.secret")
+
+	secret, err := LoadSecret(filename)
+	require.NoError(t, err)
+	assert.Len(t, secret, 2*MinSecretLength)
+
+	info, err := os.Stat(filename)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	again, err := LoadSecret(filename)
+	require.NoError(t, err)
+	assert.Equal(t, secret, again, "the saved secret is reused")
+
+	err = os.WriteFile(filename, []byte("short\n"), 0600)
+	require.NoError(t, err)
+	_, err = LoadSecret(filename)
+	assert.Error(t, err)
+}
+
+func TestMiddleware(t *testing.T) {
+	oldSigner, err := NewSigner([]string{oldSecret})
+	require.NoError(t, err)
+	signer, err := NewSigner([]string{newSecret, oldSecret})
+	require.NoError(t, err)
+
+	handler := New(signer, nil, nil).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(UserID(r.Context())))
+	}))
+
+	tests := []struct {
+		name    string
+		cookie  string
+		userID  string
+		resigns bool
+	}{
+		{name: "No cookie"},
+		{name: "Forged cookie", cookie: "user1"},
+		{name: "Valid cookie", cookie: signer.Sign("user1"), userID: "user1"},
+		{name: "Cookie signed with an old secret", cookie: oldSigner.Sign("user1"), userID: "user1", resigns: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			if tt.cookie != "" {
+				request.AddCookie(&http.Cookie{Name: CookieName, Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			userID := w.Body.String()
+			require.Len(t, result.Cookies(), 1)
+			cookie := result.Cookies()[0]
+
+			if tt.userID != "" {
+				assert.Equal(t, tt.userID, userID)
+			} else {
+				assert.Len(t, userID, 32)
+				assert.NotEqual(t, "user1", userID)
+			}
+
+			assert.Equal(t, signer.Sign(userID), cookie.Value)
+			assert.True(t, cookie.HttpOnly)
+			assert.Equal(t, "/", cookie.Path)
+
+			if tt.resigns {
+				assert.NotEqual(t, tt.cookie, cookie.Value)
+			}
+		})
+	}
+}
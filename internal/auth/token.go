@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// maxSubjectLength is the size of the user_id columns.
+const maxSubjectLength = 255
+
+// TokenVerifier checks JWTs sent by API clients as bearer tokens. The user
+// ID is the sub claim. Tokens must expire, nbf is checked when the token has
+// it.
+type TokenVerifier struct {
+	secret    []byte
+	publicKey *rsa.PublicKey
+	methods   []string
+}
+
+// NewTokenVerifier accepts HS256 tokens signed with secret and RS256 tokens
+// signed with the private key of publicKeyPEM. Either of them may be empty,
+// only tokens of the given kinds are accepted.
+func NewTokenVerifier(secret string, publicKeyPEM []byte) (*TokenVerifier, error) {
+	verifier := &TokenVerifier{}
+
+	if secret != "" {
+		if len(secret) < MinSecretLength {
+			return nil, fmt.Errorf("JWT secret must be at least %d bytes long", MinSecretLength)
+		}
+		verifier.secret = []byte(secret)
+		verifier.methods = append(verifier.methods, jwt.SigningMethodHS256.Alg())
+	}
+
+	if len(publicKeyPEM) > 0 {
+		publicKey, err := jwt.ParseRSAPublicKeyFromPEM(publicKeyPEM)
+		if err != nil {
+			return nil, fmt.Errorf("JWT public key: %w", err)
+		}
+		verifier.publicKey = publicKey
+		verifier.methods = append(verifier.methods, jwt.SigningMethodRS256.Alg())
+	}
+
+	if len(verifier.methods) == 0 {
+		return nil, errors.New("a JWT secret or public key is required")
+	}
+
+	return verifier, nil
+}
+
+// LoadTokenVerifier is NewTokenVerifier with the public key read from
+// publicKeyPath, if it is not empty.
+func LoadTokenVerifier(secret, publicKeyPath string) (*TokenVerifier, error) {
+	var publicKeyPEM []byte
+
+	if publicKeyPath != "" {
+		var err error
+		publicKeyPEM, err = os.ReadFile(publicKeyPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return NewTokenVerifier(secret, publicKeyPEM)
+}
+
+// Verify returns the user ID of a valid token. A nil TokenVerifier accepts
+// no tokens.
+func (v *TokenVerifier) Verify(token string) (string, error) {
+	if v == nil {
+		return "", errors.New("bearer tokens are not accepted")
+	}
+
+	claims := jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(token, &claims, v.key, jwt.WithValidMethods(v.methods), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+
+	if claims.Subject == "" {
+		return "", errors.New("token has no sub claim")
+	}
+
+	// User IDs are stored in space separated rows and varchar columns.
+	if len(claims.Subject) > maxSubjectLength || strings.IndexFunc(claims.Subject, unicode.IsSpace) >= 0 {
+		return "", fmt.Errorf("sub claim must be at most %d bytes without whitespace", maxSubjectLength)
+	}
+
+	return claims.Subject, nil
+}
+
+func (v *TokenVerifier) key(token *jwt.Token) (any, error) {
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		return v.secret, nil
+	case jwt.SigningMethodRS256.Alg():
+		return v.publicKey, nil
+	default:
+		return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+	}
+}
@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenVerifier(t *testing.T) {
+	secret := strings.Repeat("j", MinSecretLength)
+	privateKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	publicKey, err := x509.MarshalPKIXPublicKey(&privateKey.PublicKey)
+	require.NoError(t, err)
+	publicKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+
+	_, err = NewTokenVerifier("", nil)
+	assert.Error(t, err)
+	_, err = NewTokenVerifier("short", nil)
+	assert.Error(t, err)
+
+	both, err := NewTokenVerifier(secret, publicKeyPEM)
+	require.NoError(t, err)
+	rsaOnly, err := NewTokenVerifier("", publicKeyPEM)
+	require.NoError(t, err)
+
+	sign := func(method jwt.SigningMethod, key any, claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+	hour := time.Now().Add(time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		verifier *TokenVerifier
+		token    string
+		userID   string
+	}{
+		{
+			name:     "HS256",
+			verifier: both,
+			token:    sign(jwt.SigningMethodHS256, []byte(secret), jwt.MapClaims{"sub": "user1", "exp": hour}),
+			userID:   "user1",
+		},
+		{
+			name:     "RS256",
+			verifier: both,
+			token:    sign(jwt.SigningMethodRS256, privateKey, jwt.MapClaims{"sub": "user1", "exp": hour}),
+			userID:   "user1",
+		},
+		{
+			name:     "No expiration",
+			verifier: both,
+			token:    sign(jwt.SigningMethodHS256, []byte(secret), jwt.MapClaims{"sub": "user1"}),
+		},
+		{
+			name:     "Subject with whitespace",
+			verifier: both,
+			token:    sign(jwt.SigningMethodHS256, []byte(secret), jwt.MapClaims{"sub": "user 1", "exp": hour}),
+		},
+		{
+			name:     "Subject too long",
+			verifier: both,
+			token:    sign(jwt.SigningMethodHS256, []byte(secret), jwt.MapClaims{"sub": strings.Repeat("u", maxSubjectLength+1), "exp": hour}),
+		},
+		{
+			name:     "Expired",
+			verifier: both,
+			token:    sign(jwt.SigningMethodHS256, []byte(secret), jwt.MapClaims{"sub": "user1", "exp": time.Now().Add(-time.Minute).Unix()}),
+		},
+		{
+			name:     "Wrong secret",
+			verifier: both,
+			token:    sign(jwt.SigningMethodHS256, []byte(strings.Repeat("x", MinSecretLength)), jwt.MapClaims{"sub": "user1", "exp": hour}),
+		},
+		{
+			name:     "No subject",
+			verifier: both,
+			token:    sign(jwt.SigningMethodHS256, []byte(secret), jwt.MapClaims{"exp": hour}),
+		},
+		{
+			name:     "Unsigned",
+			verifier: both,
+			token:    sign(jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, jwt.MapClaims{"sub": "user1", "exp": hour}),
+		},
+		{
+			name:     "HS256 signed with the public key",
+			verifier: rsaOnly,
+			token:    sign(jwt.SigningMethodHS256, publicKeyPEM, jwt.MapClaims{"sub": "user1", "exp": hour}),
+		},
+		{
+			name:  "Tokens not configured",
+			token: sign(jwt.SigningMethodHS256, []byte(secret), jwt.MapClaims{"sub": "user1", "exp": hour}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			userID, err := tt.verifier.Verify(tt.token)
+
+			if tt.userID == "" {
+				assert.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.userID, userID)
+		})
+	}
+}
+
+func TestBearerAuthentication(t *testing.T) {
+	secret := strings.Repeat("j", MinSecretLength)
+	signer, err := NewSigner([]string{newSecret})
+	require.NoError(t, err)
+	tokens, err := NewTokenVerifier(secret, nil)
+	require.NoError(t, err)
+	authenticator := New(signer, tokens, nil)
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "service", "exp": time.Now().Add(time.Hour).Unix()}).SignedString([]byte(secret))
+	require.NoError(t, err)
+	expired, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "service", "exp": time.Now().Add(-time.Minute).Unix()}).SignedString([]byte(secret))
+	require.NoError(t, err)
+
+	echo := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(UserID(r.Context())))
+	})
+
+	tests := []struct {
+		name          string
+		authorization string
+		statusCode    int
+		userID        string
+		cookie        bool
+	}{
+		{
+			name:          "Valid token",
+			authorization: "Bearer " + token,
+			statusCode:    http.StatusOK,
+			userID:        "service",
+		},
+		{
+			name:          "Invalid token does not fall back to the cookie",
+			authorization: "Bearer " + token + "x",
+			statusCode:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Expired token",
+			authorization: "Bearer " + expired,
+			statusCode:    http.StatusUnauthorized,
+		},
+		{
+			name:          "Invalid token is rejected",
+			authorization: "bearer " + token + "x",
+			statusCode:    http.StatusUnauthorized,
+		},
+		{
+			name:       "No token",
+			statusCode: http.StatusOK,
+			userID:     "user1",
+			cookie:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/api/user/urls", nil)
+			request.AddCookie(&http.Cookie{Name: CookieName, Value: signer.Sign("user1")})
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			authenticator.Middleware(echo).ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, tt.cookie, len(result.Cookies()) == 1)
+
+			if tt.statusCode == http.StatusOK {
+				assert.Equal(t, tt.userID, w.Body.String())
+			} else {
+				assert.NotEmpty(t, result.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package blocklist
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ReservedWords are the first path segments used by the router. A short code
+// equal to one of them would be shadowed by (or shadow) a real route.
+var ReservedWords = []string{"api", "ping"}
+
+var ErrBlocked = errors.New("short code is not allowed")
+
+// Blocklist holds reserved words, which block a code only on an exact match,
+// and blocked words (profanity), which block any code containing them.
+// Both comparisons are case-insensitive.
+type Blocklist struct {
+	reserved map[string]struct{}
+	blocked  []string
+}
+
+func New(reserved, blocked []string) *Blocklist {
+	b := &Blocklist{
+		reserved: map[string]struct{}{},
+		blocked:  make([]string, 0, len(blocked)),
+	}
+
+	for _, word := range reserved {
+		b.reserved[strings.ToLower(word)] = struct{}{}
+	}
+
+	for _, word := range blocked {
+		b.blocked = append(b.blocked, strings.ToLower(word))
+	}
+
+	return b
+}
+
+// Load reads a blocklist file with one word per line on top of the built-in
+// ReservedWords. Lines starting with "=" are reserved words, any other line
+// is a blocked word. Empty lines and lines starting with "#" are skipped.
+func Load(filename string) (*Blocklist, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reserved := append([]string{}, ReservedWords...)
+	blocked := make([]string, 0)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "=") {
+			reserved = append(reserved, strings.TrimPrefix(line, "="))
+			continue
+		}
+
+		blocked = append(blocked, line)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return New(reserved, blocked), nil
+}
+
+// Check returns an error wrapping ErrBlocked if code may not be used as a
+// short code.
+func (b *Blocklist) Check(code string) error {
+	if b == nil {
+		return nil
+	}
+
+	lowerCode := strings.ToLower(code)
+
+	if _, ok := b.reserved[lowerCode]; ok {
+		return fmt.Errorf("%w: %q is reserved", ErrBlocked, code)
+	}
+
+	for _, word := range b.blocked {
+		if word != "" && strings.Contains(lowerCode, word) {
+			return fmt.Errorf("%w: %q contains blocked word", ErrBlocked, code)
+		}
+	}
+
+	return nil
+}
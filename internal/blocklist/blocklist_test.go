@@ -0,0 +1,64 @@
+package blocklist
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	list := New(ReservedWords, []string{"Bad"})
+
+	tests := []struct {
+		name    string
+		code    string
+		blocked bool
+	}{
+		{
+			name:    "Regular code",
+			code:    "qwErty",
+			blocked: false,
+		},
+		{
+			name:    "Reserved route",
+			code:    "API",
+			blocked: true,
+		},
+		{
+			name:    "Reserved word inside code",
+			code:    "rapid",
+			blocked: false,
+		},
+		{
+			name:    "Blocked word inside code",
+			code:    "xxbADx",
+			blocked: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := list.Check(tt.code)
+			assert.Equal(t, tt.blocked, errors.Is(err, ErrBlocked))
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "blocklist.txt")
+	err := os.WriteFile(filename, []byte("# comment\n\n=admin\nugly\n"), 0600)
+	require.NoError(t, err)
+
+	list, err := Load(filename)
+	require.NoError(t, err)
+
+	assert.Error(t, list.Check("ping"))
+	assert.Error(t, list.Check("admin"))
+	assert.NoError(t, list.Check("admins"))
+	assert.Error(t, list.Check("soUGLY"))
+	assert.NoError(t, list.Check("comment"))
+}
@@ -0,0 +1,54 @@
+package clicks
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+// NewClick describes a redirect of shortenURL served for r. The location is
+// looked up in geo, which may be nil, before the IP is anonymized.
+func NewClick(r *http.Request, shortenURL string, geo *geoip.DB) storage.Click {
+	ip := ClientIP(r)
+
+	return storage.Click{
+		ShortenURL:     shortenURL,
+		Time:           time.Now().UTC(),
+		Referer:        r.Referer(),
+		UserAgent:      r.UserAgent(),
+		IP:             AnonymizeIP(ip),
+		AcceptLanguage: r.Header.Get("Accept-Language"),
+		Location:       geo.Lookup(ip),
+	}
+}
+
+// ClientIP returns the IP the request came from. Behind a proxy that is the
+// proxy, unless ratelimit.RealIP put the client in its place.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// AnonymizeIP zeroes the last octet of an IPv4 address and the last 80 bits
+// of an IPv6 one, which is enough for analytics and keeps a visitor from
+// being identified. Anything else becomes an empty string.
+func AnonymizeIP(address string) string {
+	ip := net.ParseIP(address)
+
+	if ip == nil {
+		return ""
+	}
+
+	if ipv4 := ip.To4(); ipv4 != nil {
+		return ipv4.Mask(net.CIDRMask(24, 32)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(48, 128)).String()
+}
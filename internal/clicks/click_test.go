@@ -0,0 +1,84 @@
+package clicks
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
+)
+
+func TestAnonymizeIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		address string
+		want    string
+	}{
+		{
+			name:    "IPv4",
+			address: "192.168.10.42",
+			want:    "192.168.10.0",
+		},
+		{
+			name:    "IPv6",
+			address: "2001:db8:85a3:8d3:1319:8a2e:370:7348",
+			want:    "2001:db8:85a3::",
+		},
+		{
+			name:    "Not an IP",
+			address: "pipe",
+			want:    "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, AnonymizeIP(tt.address))
+		})
+	}
+}
+
+func TestNewClick(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "geoip.csv")
+	err := os.WriteFile(filename, []byte("5.8.10.42/32,RU,Moscow\n"), 0600)
+	require.NoError(t, err)
+
+	geo, err := geoip.Load(filename)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		wantIP     string
+		want       geoip.Location
+	}{
+		{
+			name:       "Located by the full address",
+			remoteAddr: "5.8.10.42:1234",
+			wantIP:     "5.8.10.0",
+			want:       geoip.Location{Country: "RU", Region: "Moscow"},
+		},
+		{
+			name:       "Unknown address",
+			remoteAddr: "8.8.8.8:1234",
+			wantIP:     "8.8.8.0",
+			want:       geoip.Location{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/abcdef", nil)
+			request.RemoteAddr = tt.remoteAddr
+
+			click := NewClick(request, "abcdef", geo)
+			assert.Equal(t, tt.wantIP, click.IP)
+			assert.Equal(t, tt.want, click.Location)
+		})
+	}
+}
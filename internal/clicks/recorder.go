@@ -0,0 +1,106 @@
+package clicks
+
+import (
+	"log"
+	"time"
+
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	"github.com/VadimFilimonov/urlshortener/internal/useragent"
+)
+
+const (
+	bufferSize    = 4096
+	batchSize     = 256
+	flushInterval = time.Second
+)
+
+type clickStorage interface {
+	AddClicks(clicks []storage.Click) error
+}
+
+// Recorder collects clicks in the background, classifies their User-Agent
+// and writes them to storage in batches, so redirects never wait for the
+// database or the file system.
+type Recorder struct {
+	data   clickStorage
+	clicks chan storage.Click
+	done   chan struct{}
+}
+
+// NewRecorder starts a recorder.
+func NewRecorder(data clickStorage) *Recorder {
+	r := &Recorder{
+		data:   data,
+		clicks: make(chan storage.Click, bufferSize),
+		done:   make(chan struct{}),
+	}
+
+	go r.run()
+
+	return r
+}
+
+// Record queues click without blocking. When the buffer is full the click is
+// dropped, losing a count is better than slowing down a redirect. A nil
+// Recorder ignores clicks.
+func (r *Recorder) Record(click storage.Click) {
+	if r == nil {
+		return
+	}
+
+	select {
+	case r.clicks <- click:
+	default:
+		log.Println("clicks buffer is full, click dropped")
+	}
+}
+
+// Close writes queued clicks and stops the recorder. Record must not be
+// called after Close.
+func (r *Recorder) Close() {
+	close(r.clicks)
+	<-r.done
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]storage.Click, 0, batchSize)
+
+	for {
+		select {
+		case click, ok := <-r.clicks:
+			if !ok {
+				r.flush(batch)
+				return
+			}
+
+			batch = append(batch, click)
+			if len(batch) >= batchSize {
+				batch = r.flush(batch)
+			}
+		case <-ticker.C:
+			batch = r.flush(batch)
+		}
+	}
+}
+
+func (r *Recorder) flush(batch []storage.Click) []storage.Click {
+	if len(batch) == 0 {
+		return batch
+	}
+
+	for i := range batch {
+		batch[i].Info = useragent.Classify(batch[i].UserAgent)
+	}
+
+	err := r.data.AddClicks(batch)
+	if err != nil {
+		log.Println(err.Error())
+	}
+
+	return make([]storage.Click, 0, batchSize)
+}
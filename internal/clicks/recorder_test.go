@@ -0,0 +1,49 @@
+package clicks
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+type fakeStorage struct {
+	mu      sync.Mutex
+	batches [][]storage.Click
+}
+
+func (f *fakeStorage) AddClicks(clicks []storage.Click) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.batches = append(f.batches, clicks)
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	data := &fakeStorage{}
+	recorder := NewRecorder(data)
+
+	for i := 0; i < batchSize+1; i += 1 {
+		recorder.Record(storage.Click{ShortenURL: "abcdef", Time: time.Now()})
+	}
+	recorder.Close()
+
+	total := 0
+	for _, batch := range data.batches {
+		assert.LessOrEqual(t, len(batch), batchSize)
+		total += len(batch)
+	}
+	assert.Equal(t, batchSize+1, total)
+}
+
+func TestNilRecorder(t *testing.T) {
+	var recorder *Recorder
+
+	assert.NotPanics(t, func() {
+		recorder.Record(storage.Click{ShortenURL: "abcdef", Time: time.Now()})
+	})
+}
@@ -3,15 +3,47 @@ package config
 import (
 	"flag"
 	"log"
+	"strings"
 
 	env "github.com/caarlos0/env/v6"
+	"golang.org/x/exp/slices"
+
+	"github.com/VadimFilimonov/urlshortener/internal/constants"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
 )
 
 type Config struct {
-	ServerAddress   string `env:"SERVER_ADDRESS"`
-	BaseURL         string `env:"BASE_URL"`
-	FileStoragePath string `env:"FILE_STORAGE_PATH"`
-	DatabaseDNS     string `env:"DATABASE_DSN"`
+	ServerAddress         string   `env:"SERVER_ADDRESS"`
+	BaseURL               string   `env:"BASE_URL"`
+	FileStoragePath       string   `env:"FILE_STORAGE_PATH"`
+	DatabaseDNS           string   `env:"DATABASE_DSN"`
+	BlocklistPath         string   `env:"BLOCKLIST_PATH"`
+	AllowedSchemes        []string `env:"ALLOWED_SCHEMES" envSeparator:","`
+	DedupePolicy          string   `env:"DEDUPE_POLICY"`
+	DestinationPolicyPath string   `env:"DESTINATION_POLICY_PATH"`
+	DefaultRedirectType   int      `env:"DEFAULT_REDIRECT_TYPE"`
+	CampaignsPath         string   `env:"CAMPAIGNS_PATH"`
+	GeoIPPath             string   `env:"GEOIP_PATH"`
+	PlaceholderPath       string   `env:"PLACEHOLDER_PATH"`
+	// CookieSecrets sign the user ID cookie. The first one signs new
+	// cookies, the others are only accepted to rotate secrets. Without them
+	// a generated secret is kept in FileStoragePath with a .secret suffix.
+	CookieSecrets []string `env:"COOKIE_SECRETS" envSeparator:","`
+	// API clients may authenticate with HS256 JWTs signed with JWTSecret or
+	// RS256 ones verified with the PEM public key at JWTPublicKeyPath.
+	JWTSecret        string `env:"JWT_SECRET"`
+	JWTPublicKeyPath string `env:"JWT_PUBLIC_KEY_PATH"`
+	// Rates such as "100/1m" of creating links per user and of redirects
+	// per client IP. "off", the default, lifts a limit. With
+	// RateLimitShared and a database the buckets are kept in it and shared
+	// between instances.
+	RateLimitCreate   string `env:"RATE_LIMIT_CREATE"`
+	RateLimitRedirect string `env:"RATE_LIMIT_REDIRECT"`
+	RateLimitShared   bool   `env:"RATE_LIMIT_SHARED"`
+	// TrustedProxyHeader is the header a load balancer in front of every
+	// instance puts the client IP in, such as X-Forwarded-For. Without it
+	// limits and analytics see the IP of the load balancer.
+	TrustedProxyHeader string `env:"TRUSTED_PROXY_HEADER"`
 }
 
 func New() Config {
@@ -29,6 +61,21 @@ func (c *Config) Parse() {
 	BaseURL := flag.String("b", "http://localhost:8080", "базовый адрес результирующего сокращённого URL")
 	FileStoragePath := flag.String("f", "", "путь до файла с сокращёнными URL")
 	DatabaseDNS := flag.String("d", "", "адрес подключения к БД")
+	BlocklistPath := flag.String("blocklist", "", "путь до файла с зарезервированными и запрещёнными словами")
+	AllowedSchemes := flag.String("schemes", strings.Join(validation.DefaultSchemes, ","), "разрешённые схемы сокращаемых URL через запятую")
+	DedupePolicy := flag.String("dedupe", "global", "повторное использование сокращённых URL: none, user или global")
+	DestinationPolicyPath := flag.String("destination-policy", "", "путь до файла с правилами allow/block для адресов назначения")
+	DefaultRedirectType := flag.Int("redirect-type", constants.DefaultRedirectType, "HTTP-статус перенаправления по умолчанию: 301, 302, 307 или 308")
+	CampaignsPath := flag.String("campaigns", "", "путь до JSON-файла с UTM-пресетами кампаний")
+	GeoIPPath := flag.String("geoip", "", "путь до CSV-файла с диапазонами IP-адресов и их странами и регионами")
+	PlaceholderPath := flag.String("placeholder", "", "путь до HTML-страницы для ещё не активированных ссылок")
+	CookieSecrets := flag.String("cookie-secrets", "", "секреты подписи cookie с ID пользователя через запятую, первым подписываются новые cookie")
+	JWTSecret := flag.String("jwt-secret", "", "секрет для проверки JWT с алгоритмом HS256")
+	JWTPublicKeyPath := flag.String("jwt-public-key", "", "путь до PEM-файла с открытым ключом для проверки JWT с алгоритмом RS256")
+	RateLimitCreate := flag.String("rate-limit-create", "off", "лимит создания ссылок одним пользователем вида 100/1m или off")
+	RateLimitRedirect := flag.String("rate-limit-redirect", "off", "лимит переходов по ссылкам с одного IP-адреса вида 600/1m или off")
+	RateLimitShared := flag.Bool("rate-limit-shared", false, "хранить состояние лимитов в БД, общей для всех экземпляров")
+	TrustedProxyHeader := flag.String("trusted-proxy-header", "", "заголовок с IP-адресом клиента от балансировщика, например X-Forwarded-For")
 	flag.Parse()
 
 	if c.ServerAddress == "" {
@@ -46,4 +93,68 @@ func (c *Config) Parse() {
 	if c.DatabaseDNS == "" {
 		c.DatabaseDNS = *DatabaseDNS
 	}
+
+	if c.BlocklistPath == "" {
+		c.BlocklistPath = *BlocklistPath
+	}
+
+	if len(c.AllowedSchemes) == 0 {
+		c.AllowedSchemes = strings.Split(*AllowedSchemes, ",")
+	}
+
+	if c.DedupePolicy == "" {
+		c.DedupePolicy = *DedupePolicy
+	}
+
+	if c.DestinationPolicyPath == "" {
+		c.DestinationPolicyPath = *DestinationPolicyPath
+	}
+
+	if c.DefaultRedirectType == 0 {
+		c.DefaultRedirectType = *DefaultRedirectType
+	}
+
+	if c.CampaignsPath == "" {
+		c.CampaignsPath = *CampaignsPath
+	}
+
+	if c.GeoIPPath == "" {
+		c.GeoIPPath = *GeoIPPath
+	}
+
+	if c.PlaceholderPath == "" {
+		c.PlaceholderPath = *PlaceholderPath
+	}
+
+	if len(c.CookieSecrets) == 0 && *CookieSecrets != "" {
+		c.CookieSecrets = strings.Split(*CookieSecrets, ",")
+	}
+
+	if c.JWTSecret == "" {
+		c.JWTSecret = *JWTSecret
+	}
+
+	if c.JWTPublicKeyPath == "" {
+		c.JWTPublicKeyPath = *JWTPublicKeyPath
+	}
+
+	if c.RateLimitCreate == "" {
+		c.RateLimitCreate = *RateLimitCreate
+	}
+
+	if c.RateLimitRedirect == "" {
+		c.RateLimitRedirect = *RateLimitRedirect
+	}
+
+	if !c.RateLimitShared {
+		c.RateLimitShared = *RateLimitShared
+	}
+
+	if c.TrustedProxyHeader == "" {
+		c.TrustedProxyHeader = *TrustedProxyHeader
+	}
+
+	if !slices.Contains(constants.RedirectTypes, c.DefaultRedirectType) {
+		log.Fatalf("redirect type must be one of %v", constants.RedirectTypes)
+	}
 }
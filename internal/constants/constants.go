@@ -3,3 +3,8 @@ package constants
 import "errors"
 
 var ErrURLAlreadyExists = errors.New("url already exists")
+
+// RedirectTypes are the HTTP statuses a short link may redirect with.
+var RedirectTypes = []int{301, 302, 307, 308}
+
+const DefaultRedirectType = 307
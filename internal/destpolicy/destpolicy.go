@@ -0,0 +1,185 @@
+package destpolicy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/VadimFilimonov/urlshortener/internal/utils/filewatch"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
+)
+
+const (
+	actionAllow = "allow"
+	actionBlock = "block"
+)
+
+// Error is returned by Check when a destination is not allowed. Rule is the
+// matched rule as written in the policy file.
+type Error struct {
+	Host string
+	Rule string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("destination %q is denied by rule %q", e.Host, e.Rule)
+}
+
+type rule struct {
+	text    string
+	action  string
+	host    string
+	network *net.IPNet
+}
+
+// matches reports whether hostname is equal to the rule host, is a
+// subdomain of a "*.domain" rule or is an IP literal inside a CIDR rule.
+func (r rule) matches(hostname string) bool {
+	if r.network != nil {
+		ip := net.ParseIP(hostname)
+		return ip != nil && r.network.Contains(ip)
+	}
+
+	if strings.HasPrefix(r.host, "*.") {
+		return strings.HasSuffix(hostname, r.host[1:])
+	}
+
+	return hostname == r.host
+}
+
+// Policy checks destination hosts against block and allow rules. A host
+// matching a block rule is denied. If there is at least one allow rule, a
+// host matching none of them is denied too.
+type Policy struct {
+	filename string
+	mu       sync.RWMutex
+	rules    []rule
+}
+
+// Load reads a policy file with one "allow <pattern>" or "block <pattern>"
+// rule per line, where pattern is a host, "*.domain" or a CIDR. Empty lines
+// and lines starting with "#" are skipped.
+func Load(filename string) (*Policy, error) {
+	p := &Policy{filename: filename}
+
+	err := p.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Watch reloads the policy whenever its file changes.
+func (p *Policy) Watch(interval time.Duration) {
+	filewatch.Watch(p.filename, interval, p.reload)
+}
+
+func (p *Policy) reload() error {
+	file, err := os.Open(p.filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	rules := make([]rule, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule, err := parseRule(line)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, rule)
+	}
+
+	err = scanner.Err()
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	p.rules = rules
+	p.mu.Unlock()
+
+	return nil
+}
+
+func parseRule(line string) (rule, error) {
+	fields := strings.Fields(line)
+
+	if len(fields) != 2 || (fields[0] != actionAllow && fields[0] != actionBlock) {
+		return rule{}, fmt.Errorf("malformed policy rule %q", line)
+	}
+
+	r := rule{
+		text:   strings.Join(fields, " "),
+		action: fields[0],
+		host:   strings.TrimSuffix(strings.ToLower(fields[1]), "."),
+	}
+
+	if strings.Contains(r.host, "/") {
+		_, network, err := net.ParseCIDR(r.host)
+		if err != nil {
+			return rule{}, fmt.Errorf("malformed policy rule %q: %w", line, err)
+		}
+		r.network = network
+	}
+
+	return r, nil
+}
+
+// Check returns *Error if rawURL may not be shortened. The host is matched
+// in the form browsers resolve it, see validation.CanonicalHostname. A nil
+// Policy allows everything.
+func (p *Policy) Check(rawURL string) error {
+	if p == nil {
+		return nil
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	hostname, err := validation.CanonicalHostname(u.Hostname())
+	if err != nil {
+		return err
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	hasAllowRules := false
+
+	for _, r := range p.rules {
+		if r.action == actionBlock && r.matches(hostname) {
+			return &Error{Host: hostname, Rule: r.text}
+		}
+
+		hasAllowRules = hasAllowRules || r.action == actionAllow
+	}
+
+	if !hasAllowRules {
+		return nil
+	}
+
+	for _, r := range p.rules {
+		if r.action == actionAllow && r.matches(hostname) {
+			return nil
+		}
+	}
+
+	return &Error{Host: hostname, Rule: "not in allowlist"}
+}
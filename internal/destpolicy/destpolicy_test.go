@@ -0,0 +1,156 @@
+package destpolicy
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loadPolicy(t *testing.T, rules string) *Policy {
+	filename := filepath.Join(t.TempDir(), "policy.txt")
+	err := os.WriteFile(filename, []byte(rules), 0600)
+	require.NoError(t, err)
+
+	policy, err := Load(filename)
+	require.NoError(t, err)
+
+	return policy
+}
+
+func TestCheck(t *testing.T) {
+	blocklist := loadPolicy(t, "# phishing\nblock evil.com\nblock *.bad.org\nblock 10.0.0.0/8\nblock 127.0.0.0/8\n")
+	allowlist := loadPolicy(t, "allow *.company.com\nallow company.com\nblock intranet.company.com\n")
+
+	tests := []struct {
+		name   string
+		policy *Policy
+		url    string
+		rule   string
+	}{
+		{
+			name:   "Not blocked",
+			policy: blocklist,
+			url:    "https://example.com",
+		},
+		{
+			name:   "Exact host",
+			policy: blocklist,
+			url:    "https://EVIL.com/login",
+			rule:   "block evil.com",
+		},
+		{
+			name:   "Subdomain of exact host",
+			policy: blocklist,
+			url:    "https://login.evil.com",
+		},
+		{
+			name:   "Wildcard subdomain",
+			policy: blocklist,
+			url:    "https://a.b.bad.org",
+			rule:   "block *.bad.org",
+		},
+		{
+			name:   "IP inside CIDR",
+			policy: blocklist,
+			url:    "http://10.1.2.3:8080/",
+			rule:   "block 10.0.0.0/8",
+		},
+		{
+			name:   "Exact host with trailing dot",
+			policy: blocklist,
+			url:    "http://evil.com./x",
+			rule:   "block evil.com",
+		},
+		{
+			name:   "Wildcard subdomain with trailing dot",
+			policy: blocklist,
+			url:    "http://a.bad.org./x",
+			rule:   "block *.bad.org",
+		},
+		{
+			name:   "IP as one decimal number",
+			policy: blocklist,
+			url:    "http://2130706433/",
+			rule:   "block 127.0.0.0/8",
+		},
+		{
+			name:   "IP with a hex part",
+			policy: blocklist,
+			url:    "http://0x7f.0.0.1/",
+			rule:   "block 127.0.0.0/8",
+		},
+		{
+			name:   "IP with an octal part and fewer parts",
+			policy: blocklist,
+			url:    "http://012.1/",
+			rule:   "block 10.0.0.0/8",
+		},
+		{
+			name:   "Allowed host with trailing dot",
+			policy: allowlist,
+			url:    "https://docs.company.com.",
+		},
+		{
+			name:   "Allowed host",
+			policy: allowlist,
+			url:    "https://docs.company.com",
+		},
+		{
+			name:   "Block wins over allow",
+			policy: allowlist,
+			url:    "https://intranet.company.com",
+			rule:   "block intranet.company.com",
+		},
+		{
+			name:   "Not in allowlist",
+			policy: allowlist,
+			url:    "https://example.com",
+			rule:   "not in allowlist",
+		},
+		{
+			name:   "No policy",
+			policy: nil,
+			url:    "https://evil.com",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.policy.Check(tt.url)
+
+			if tt.rule == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var policyErr *Error
+			require.True(t, errors.As(err, &policyErr))
+			assert.Equal(t, tt.rule, policyErr.Rule)
+		})
+	}
+}
+
+func TestCheckMalformedIP(t *testing.T) {
+	blocklist := loadPolicy(t, "block 127.0.0.0/8\n")
+
+	for _, url := range []string{"http://127.0.0.0.1/", "http://127.0.0.256/", "http://1.2.3.09/"} {
+		err := blocklist.Check(url)
+
+		var policyErr *Error
+		assert.Error(t, err, url)
+		assert.False(t, errors.As(err, &policyErr), url)
+	}
+}
+
+func TestLoadMalformed(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "policy.txt")
+	err := os.WriteFile(filename, []byte("deny evil.com\n"), 0600)
+	require.NoError(t, err)
+
+	_, err = Load(filename)
+	assert.Error(t, err)
+}
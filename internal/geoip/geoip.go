@@ -0,0 +1,209 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/VadimFilimonov/urlshortener/internal/utils/filewatch"
+)
+
+// Location of an IP address. Country is an ISO 3166-1 alpha-2 code.
+type Location struct {
+	Country string `json:"country,omitempty"`
+	Region  string `json:"region,omitempty"`
+}
+
+// MaxRegionLength is the length regions are cut to, the size of the column
+// they are stored in with clicks.
+const MaxRegionLength = 128
+
+type ipRange struct {
+	start    net.IP
+	end      net.IP
+	location Location
+}
+
+// DB resolves IP addresses to locations with a binary search over sorted,
+// non-overlapping ranges held in memory.
+type DB struct {
+	filename string
+	mu       sync.RWMutex
+	ranges   []ipRange
+}
+
+// Load reads a CSV database. Every row is either
+// "network,country[,region]" with network in CIDR notation or
+// "start_ip,end_ip,country[,region]". A header row is skipped. Countries
+// must be ISO 3166-1 alpha-2 codes, so MaxMind blocks files, which refer to
+// locations by geoname ID, have to be joined with their locations file
+// first. Regions are cut to MaxRegionLength characters.
+func Load(filename string) (*DB, error) {
+	db := &DB{filename: filename}
+
+	err := db.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Watch reloads the database whenever its file changes.
+func (db *DB) Watch(interval time.Duration) {
+	filewatch.Watch(db.filename, interval, db.reload)
+}
+
+func (db *DB) reload() error {
+	file, err := os.Open(db.filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	ranges, err := parse(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w", db.filename, err)
+	}
+
+	db.mu.Lock()
+	db.ranges = ranges
+	db.mu.Unlock()
+
+	return nil
+}
+
+func parse(reader io.Reader) ([]ipRange, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	ranges := make([]ipRange, 0)
+
+	for line := 1; ; line += 1 {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := parseRecord(record)
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		ranges = append(ranges, r)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return bytes.Compare(ranges[i].start, ranges[j].start) < 0
+	})
+
+	return ranges, nil
+}
+
+func parseRecord(record []string) (ipRange, error) {
+	var r ipRange
+
+	if len(record) > 0 && strings.Contains(record[0], "/") {
+		_, network, err := net.ParseCIDR(record[0])
+		if err != nil {
+			return r, err
+		}
+
+		r.start = network.IP.To16()
+		r.end = make(net.IP, len(network.IP))
+		for i := range network.IP {
+			r.end[i] = network.IP[i] | ^network.Mask[i]
+		}
+		r.end = r.end.To16()
+		record = record[1:]
+	} else {
+		if len(record) < 3 {
+			return r, fmt.Errorf("expected start, end and country, got %q", record)
+		}
+
+		r.start = net.ParseIP(record[0]).To16()
+		r.end = net.ParseIP(record[1]).To16()
+		if r.start == nil || r.end == nil {
+			return r, fmt.Errorf("malformed range %q - %q", record[0], record[1])
+		}
+		record = record[2:]
+	}
+
+	if len(record) == 0 || record[0] == "" {
+		return r, fmt.Errorf("country is missing")
+	}
+
+	if !isCountryCode(record[0]) {
+		return r, fmt.Errorf("country %q is not a two-letter ISO 3166-1 code", record[0])
+	}
+
+	r.location.Country = strings.ToUpper(record[0])
+	if len(record) > 1 {
+		r.location.Region = truncate(record[1], MaxRegionLength)
+	}
+
+	return r, nil
+}
+
+func isCountryCode(value string) bool {
+	if len(value) != 2 {
+		return false
+	}
+
+	for _, c := range strings.ToUpper(value) {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// truncate cuts value to at most length characters.
+func truncate(value string, length int) string {
+	if utf8.RuneCountInString(value) <= length {
+		return value
+	}
+
+	return string([]rune(value)[:length])
+}
+
+// Lookup returns the location of address, or an empty Location if it is
+// unknown. A nil DB knows nothing.
+func (db *DB) Lookup(address string) Location {
+	if db == nil {
+		return Location{}
+	}
+
+	ip := net.ParseIP(address).To16()
+	if ip == nil {
+		return Location{}
+	}
+
+	db.mu.RLock()
+	defer db.mu.RUnlock()
+
+	index := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start, ip) > 0
+	}) - 1
+
+	if index < 0 || bytes.Compare(ip, db.ranges[index].end) > 0 {
+		return Location{}
+	}
+
+	return db.ranges[index].location
+}
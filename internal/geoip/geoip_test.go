@@ -0,0 +1,119 @@
+package geoip
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLookup(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "geoip.csv")
+	err := os.WriteFile(filename, []byte(`network,country,region
+5.8.0.0/16,ru,Moscow
+2a02:6b8::/32,RU,
+8.8.8.0,8.8.8.255,US,California
+`), 0600)
+	require.NoError(t, err)
+
+	db, err := Load(filename)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		address string
+		want    Location
+	}{
+		{
+			name:    "Inside CIDR",
+			address: "5.8.10.0",
+			want:    Location{Country: "RU", Region: "Moscow"},
+		},
+		{
+			name:    "Inside range",
+			address: "8.8.8.8",
+			want:    Location{Country: "US", Region: "California"},
+		},
+		{
+			name:    "IPv6",
+			address: "2a02:6b8::",
+			want:    Location{Country: "RU"},
+		},
+		{
+			name:    "Between ranges",
+			address: "7.0.0.1",
+			want:    Location{},
+		},
+		{
+			name:    "Not an IP",
+			address: "",
+			want:    Location{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, db.Lookup(tt.address))
+		})
+	}
+}
+
+func TestLoadValidates(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "MaxMind blocks file",
+			content: `network,geoname_id,registered_country_geoname_id,represented_country_geoname_id,is_anonymous_proxy,is_satellite_provider
+1.0.0.0/24,2077456,2077456,,0,0
+`,
+			wantErr: `line 2: country "2077456" is not a two-letter ISO 3166-1 code`,
+		},
+		{
+			name:    "Country that is not letters",
+			content: "1.0.0.0/24,AU\n1.0.1.0/24,1A\n",
+			wantErr: "line 2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := filepath.Join(t.TempDir(), "geoip.csv")
+			require.NoError(t, os.WriteFile(filename, []byte(tt.content), 0600))
+
+			_, err := Load(filename)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.wantErr)
+		})
+	}
+}
+
+func TestLongRegion(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "geoip.csv")
+	region := strings.Repeat("я", MaxRegionLength+10)
+	require.NoError(t, os.WriteFile(filename, []byte("1.0.0.0/24,AU,"+region+"\n"), 0600))
+
+	db, err := Load(filename)
+	require.NoError(t, err)
+	assert.Equal(t, Location{Country: "AU", Region: region[:2*MaxRegionLength]}, db.Lookup("1.0.0.1"))
+}
+
+func TestReload(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "geoip.csv")
+	err := os.WriteFile(filename, []byte("5.8.0.0/16,RU\n"), 0600)
+	require.NoError(t, err)
+
+	db, err := Load(filename)
+	require.NoError(t, err)
+
+	err = os.WriteFile(filename, []byte("5.8.0.0/16,KZ\n"), 0600)
+	require.NoError(t, err)
+	require.NoError(t, db.reload())
+
+	assert.Equal(t, Location{Country: "KZ"}, db.Lookup("5.8.0.1"))
+}
@@ -0,0 +1,214 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/clicks"
+	"github.com/VadimFilimonov/urlshortener/internal/ratelimit"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+type AccountInput struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	// Claim hands the links of the anonymous user the request comes from
+	// over to the account.
+	Claim bool `json:"claim,omitempty"`
+}
+
+type AccountOutput struct {
+	ID           string `json:"id"`
+	Email        string `json:"email"`
+	ClaimedLinks int    `json:"claimed_links"`
+}
+
+const (
+	maxEmailLength           = 254
+	minAccountPasswordLength = 8
+	maxAccountPasswordLength = 1024
+)
+
+// NewRegister creates an account and logs the client into it.
+func NewRegister(data storage.Data, signer *auth.Signer) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input, ok := readAccountInput(w, r)
+		if !ok {
+			return
+		}
+
+		length := utf8.RuneCountInString(input.Password)
+		if length < minAccountPasswordLength || length > maxAccountPasswordLength {
+			writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+				Error:  fmt.Sprintf("password must be %d to %d characters long", minAccountPasswordLength, maxAccountPasswordLength),
+				Reason: "invalid_password",
+			})
+			return
+		}
+
+		passwordHash, err := auth.HashPassword(input.Password)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		id, err := auth.NewUserID()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		account := storage.Account{
+			ID:           id,
+			Email:        input.Email,
+			PasswordHash: passwordHash,
+			CreatedAt:    time.Now().UTC(),
+		}
+
+		err = data.AddAccount(account)
+
+		if errors.Is(err, storage.ErrEmailTaken) {
+			writeJSONError(w, http.StatusConflict, ErrorOutput{
+				Error:  err.Error(),
+				Reason: "email_taken",
+			})
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		logIn(w, r, data, signer, account, input.Claim, http.StatusCreated)
+	}
+}
+
+// NewLogin logs the client into the account with the email and password of
+// the input. Wrong attempts are limited per email and client IP.
+func NewLogin(data storage.Data, signer *auth.Signer, attempts *ratelimit.Limiter) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		input, ok := readAccountInput(w, r)
+		if !ok {
+			return
+		}
+
+		key := input.Email + " " + clicks.ClientIP(r)
+		allowed, retryAfter := attempts.Allow(key)
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			http.Error(w, "too many login attempts", http.StatusTooManyRequests)
+			return
+		}
+
+		account, err := data.GetAccountByEmail(input.Email)
+		if err != nil && !errors.Is(err, storage.ErrAccountNotFound) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !auth.CheckPassword(account.PasswordHash, input.Password) {
+			http.Error(w, "wrong email or password", http.StatusUnauthorized)
+			return
+		}
+		attempts.Refund(key)
+
+		logIn(w, r, data, signer, account, input.Claim, http.StatusOK)
+	}
+}
+
+// NewLogout makes the client anonymous again. Its next request gets a new
+// user, the links stay with the account. The cookie is only removed from
+// the client, a copy of it is good until auth.CookieLifetime passes unused.
+func NewLogout() func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		auth.ClearCookie(w)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func readAccountInput(w http.ResponseWriter, r *http.Request) (AccountInput, bool) {
+	var input AccountInput
+	body, err := io.ReadAll(r.Body)
+	defer r.Body.Close()
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return input, false
+	}
+
+	err = json.Unmarshal(body, &input)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return input, false
+	}
+
+	address, err := mail.ParseAddress(input.Email)
+	if err != nil || address.Address != strings.TrimSpace(input.Email) || len(address.Address) > maxEmailLength {
+		writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+			Error:  fmt.Sprintf("%q is not a valid email address", input.Email),
+			Reason: "invalid_email",
+		})
+		return input, false
+	}
+	input.Email = strings.ToLower(address.Address)
+
+	return input, true
+}
+
+// logIn sets the cookie of account and writes it to the response, after
+// claiming the links of the anonymous user if claim is set.
+func logIn(w http.ResponseWriter, r *http.Request, data storage.Data, signer *auth.Signer, account storage.Account, claim bool, statusCode int) {
+	output := AccountOutput{ID: account.ID, Email: account.Email}
+
+	if claim {
+		var err error
+		output.ClaimedLinks, err = claimLinks(r, data, signer, account.ID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	response, err := json.Marshal(output)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	signer.SetCookie(w, account.ID)
+	w.Header().Add("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	w.Write(response)
+}
+
+// claimLinks hands the links of the user of the request cookie over to
+// accountID. Only anonymous users are claimed, so logging into one account
+// from another does not take the links of the first.
+func claimLinks(r *http.Request, data storage.Data, signer *auth.Signer, accountID string) (int, error) {
+	userID, ok := signer.CookieUserID(r)
+	if !ok || userID == accountID {
+		return 0, nil
+	}
+
+	_, err := data.GetAccount(userID)
+	if err == nil {
+		return 0, nil
+	}
+
+	if !errors.Is(err, storage.ErrAccountNotFound) {
+		return 0, err
+	}
+
+	return data.ClaimItems(userID, accountID)
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/ratelimit"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+func TestAccounts(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+
+	router := chi.NewRouter()
+	router.Post("/api/auth/register", NewRegister(data, testSigner))
+	router.Post("/api/auth/login", NewLogin(data, testSigner, ratelimit.New(3, time.Hour)))
+	router.Post("/api/auth/logout", NewLogout())
+	router.With(auth.New(testSigner, nil, nil).Middleware).Get("/api/user/urls", NewGetUserUrls(data, Host))
+
+	serve := func(method, target, userID, body string) (*http.Response, string) {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if userID != "" {
+			request.AddCookie(&http.Cookie{Name: auth.CookieName, Value: testSigner.Sign(userID)})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		result := w.Result()
+		defer result.Body.Close()
+		response, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+
+		return result, string(response)
+	}
+
+	cookieUser := func(result *http.Response) string {
+		require.Len(t, result.Cookies(), 1)
+		userID, ok := testSigner.Verify(result.Cookies()[0].Value)
+		require.True(t, ok)
+		return userID
+	}
+
+	_, err := data.Add("https://filimonovvadim.t.me", "anonymous", storage.Options{})
+	require.NoError(t, err)
+
+	result, response := serve(http.MethodPost, "/api/auth/register", "", `{"email":"not an email","password":"long enough"}`)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	assert.Contains(t, response, "invalid_email")
+
+	result, response = serve(http.MethodPost, "/api/auth/register", "", `{"email":"vadim@example.com","password":"short"}`)
+	assert.Equal(t, http.StatusBadRequest, result.StatusCode)
+	assert.Contains(t, response, "invalid_password")
+
+	result, response = serve(http.MethodPost, "/api/auth/register", "anonymous", `{"email":"Vadim@Example.com","password":"long enough","claim":true}`)
+	require.Equal(t, http.StatusCreated, result.StatusCode)
+
+	var account AccountOutput
+	require.NoError(t, json.Unmarshal([]byte(response), &account))
+	assert.Equal(t, "vadim@example.com", account.Email)
+	assert.Equal(t, 1, account.ClaimedLinks)
+	assert.Equal(t, account.ID, cookieUser(result))
+
+	result, _ = serve(http.MethodGet, "/api/user/urls", account.ID, "")
+	assert.Equal(t, http.StatusOK, result.StatusCode)
+	result, _ = serve(http.MethodGet, "/api/user/urls", "anonymous", "")
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+
+	result, response = serve(http.MethodPost, "/api/auth/register", "", `{"email":"vadim@example.com","password":"long enough"}`)
+	assert.Equal(t, http.StatusConflict, result.StatusCode)
+	assert.Contains(t, response, "email_taken")
+
+	result, _ = serve(http.MethodPost, "/api/auth/login", "", `{"email":"vadim@example.com","password":"wrong password"}`)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	result, _ = serve(http.MethodPost, "/api/auth/login", "", `{"email":"nobody@example.com","password":"long enough"}`)
+	assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+
+	result, response = serve(http.MethodPost, "/api/auth/register", "", `{"email":"other@example.com","password":"long enough"}`)
+	require.Equal(t, http.StatusCreated, result.StatusCode)
+	var other AccountOutput
+	require.NoError(t, json.Unmarshal([]byte(response), &other))
+
+	result, response = serve(http.MethodPost, "/api/auth/login", other.ID, `{"email":"vadim@example.com","password":"long enough","claim":true}`)
+	require.Equal(t, http.StatusOK, result.StatusCode)
+	assert.Equal(t, account.ID, cookieUser(result))
+	assert.Contains(t, response, `"claimed_links":0`, "links of another account must not be claimed")
+
+	for i := 0; i < 3; i += 1 {
+		result, _ = serve(http.MethodPost, "/api/auth/login", "", `{"email":"vadim@example.com","password":"long enough"}`)
+		assert.Equal(t, http.StatusOK, result.StatusCode, "right passwords are not limited")
+	}
+
+	for i := 0; i < 2; i += 1 {
+		result, _ = serve(http.MethodPost, "/api/auth/login", "", `{"email":"vadim@example.com","password":"wrong password"}`)
+		assert.Equal(t, http.StatusUnauthorized, result.StatusCode)
+	}
+	result, _ = serve(http.MethodPost, "/api/auth/login", "", `{"email":"vadim@example.com","password":"long enough"}`)
+	assert.Equal(t, http.StatusTooManyRequests, result.StatusCode)
+
+	result, _ = serve(http.MethodPost, "/api/auth/logout", account.ID, "")
+	assert.Equal(t, http.StatusNoContent, result.StatusCode)
+	require.Len(t, result.Cookies(), 1)
+	assert.Equal(t, -1, result.Cookies()[0].MaxAge)
+}
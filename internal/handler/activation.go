@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+// DefaultPlaceholder is shown for links which are not active yet when the
+// server has no placeholder configured.
+var DefaultPlaceholder = []byte(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Coming soon</title>
+</head>
+<body>
+<p>This link is not active yet, please come back later.</p>
+</body>
+</html>
+`)
+
+// serveInactive answers a visit to a link before it activates: a redirect to
+// the fallback URL of the link, or else the placeholder page with 404 and
+// the activation time in Retry-After. Neither may be cached, the answer
+// changes at activation.
+func serveInactive(w http.ResponseWriter, options storage.Options, placeholder []byte) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	if options.FallbackURL != "" {
+		w.Header().Set("Location", options.FallbackURL)
+		w.WriteHeader(http.StatusFound)
+		return
+	}
+
+	if placeholder == nil {
+		placeholder = DefaultPlaceholder
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Retry-After", options.ActivatesAt.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNotFound)
+	w.Write(placeholder)
+}
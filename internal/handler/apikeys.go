@@ -0,0 +1,172 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-chi/chi/v5"
+	"golang.org/x/exp/slices"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+type APIKeyInput struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// APIKeyOutput describes an API key. Key is only returned once, when the key
+// is created.
+type APIKeyOutput struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Prefix    string     `json:"prefix"`
+	Scopes    []string   `json:"scopes"`
+	CreatedAt time.Time  `json:"created_at"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	Key       string     `json:"key,omitempty"`
+}
+
+const maxAPIKeyNameLength = 255
+
+func apiKeyOutput(key storage.APIKey) APIKeyOutput {
+	return APIKeyOutput{
+		ID:        key.ID,
+		Name:      key.Name,
+		Prefix:    key.Prefix,
+		Scopes:    key.Scopes,
+		CreatedAt: key.CreatedAt,
+		RevokedAt: key.RevokedAt,
+	}
+}
+
+// NewCreateAPIKey mints an API key acting for the user within the scopes of
+// the input.
+func NewCreateAPIKey(data storage.Data) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+		body, err := io.ReadAll(r.Body)
+		defer r.Body.Close()
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var input APIKeyInput
+		err = json.Unmarshal(body, &input)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if utf8.RuneCountInString(input.Name) > maxAPIKeyNameLength {
+			writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+				Error:  fmt.Sprintf("name must not be longer than %d characters", maxAPIKeyNameLength),
+				Reason: "invalid_name",
+			})
+			return
+		}
+
+		err = auth.ValidateScopes(input.Scopes)
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+				Error:  err.Error(),
+				Reason: "invalid_scopes",
+			})
+			return
+		}
+
+		scopes := slices.Clone(input.Scopes)
+		slices.Sort(scopes)
+
+		key, id, err := auth.NewAPIKey()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		apiKey := storage.APIKey{
+			ID:        id,
+			UserID:    userIDCookieValue,
+			Name:      input.Name,
+			Prefix:    auth.DisplayPrefix(key),
+			Hash:      auth.HashAPIKey(key),
+			Scopes:    slices.Compact(scopes),
+			CreatedAt: time.Now().UTC(),
+		}
+
+		err = data.AddAPIKey(apiKey)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		output := apiKeyOutput(apiKey)
+		output.Key = key
+
+		response, err := json.Marshal(output)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusCreated)
+		w.Write(response)
+	}
+}
+
+// NewGetAPIKeys lists the API keys of the user, oldest first, without the
+// keys themselves.
+func NewGetAPIKeys(data storage.Data) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+
+		keys, err := data.GetAPIKeys(userIDCookieValue)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		slices.SortFunc(keys, func(a, b storage.APIKey) bool {
+			return a.CreatedAt.Before(b.CreatedAt)
+		})
+
+		output := make([]APIKeyOutput, len(keys))
+		for i, key := range keys {
+			output[i] = apiKeyOutput(key)
+		}
+
+		writeList(w, output)
+	}
+}
+
+// NewRevokeAPIKey revokes an API key of the user. Requests with it are
+// rejected from then on.
+func NewRevokeAPIKey(data storage.Data) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+
+		err := data.RevokeAPIKey(chi.URLParam(r, "id"), userIDCookieValue)
+
+		if errors.Is(err, storage.ErrAPIKeyNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
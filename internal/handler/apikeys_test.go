@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
+)
+
+func TestAPIKeys(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	validator := validation.New(Host, validation.DefaultSchemes)
+
+	router := chi.NewRouter()
+	router.Use(auth.New(testSigner, nil, data).Middleware)
+	router.With(auth.RequireScope(auth.ScopeShorten)).Post("/api/shorten", NewShorten(data, Host, validator, nil, nil))
+	router.With(auth.RequireScope(auth.ScopeRead)).Get("/api/user/urls", NewGetUserUrls(data, Host))
+	router.With(auth.RequireScope(auth.ScopeKeys)).Post("/api/user/keys", NewCreateAPIKey(data))
+	router.With(auth.RequireScope(auth.ScopeKeys)).Get("/api/user/keys", NewGetAPIKeys(data))
+	router.With(auth.RequireScope(auth.ScopeKeys)).Delete("/api/user/keys/{id}", NewRevokeAPIKey(data))
+
+	serve := func(method, target, key, body string) (int, string) {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		if key == "" {
+			request.AddCookie(&http.Cookie{Name: auth.CookieName, Value: testSigner.Sign("user1")})
+		} else {
+			request.Header.Set(auth.APIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		result := w.Result()
+		defer result.Body.Close()
+		response, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+
+		return result.StatusCode, string(response)
+	}
+
+	statusCode, response := serve(http.MethodPost, "/api/user/keys", "", `{"name":"CI","scopes":["admin"]}`)
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+	assert.Contains(t, response, "invalid_scopes")
+
+	statusCode, response = serve(http.MethodPost, "/api/user/keys", "", `{"name":"CI","scopes":["shorten","shorten"]}`)
+	require.Equal(t, http.StatusCreated, statusCode)
+
+	var created APIKeyOutput
+	require.NoError(t, json.Unmarshal([]byte(response), &created))
+	assert.Equal(t, []string{auth.ScopeShorten}, created.Scopes)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+
+	statusCode, _ = serve(http.MethodPost, "/api/shorten", created.Key, `{"url":"https://filimonovvadim.t.me"}`)
+	assert.Equal(t, http.StatusCreated, statusCode)
+
+	statusCode, _ = serve(http.MethodGet, "/api/user/urls", created.Key, "")
+	assert.Equal(t, http.StatusForbidden, statusCode)
+
+	statusCode, _ = serve(http.MethodPost, "/api/user/keys", created.Key, `{"scopes":["read"]}`)
+	assert.Equal(t, http.StatusForbidden, statusCode)
+
+	statusCode, response = serve(http.MethodGet, "/api/user/urls", "", "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Contains(t, response, "https://filimonovvadim.t.me")
+
+	statusCode, response = serve(http.MethodGet, "/api/user/keys", "", "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.Contains(t, response, created.ID)
+	assert.NotContains(t, response, created.Key)
+
+	statusCode, _ = serve(http.MethodDelete, "/api/user/keys/unknown", "", "")
+	assert.Equal(t, http.StatusNotFound, statusCode)
+
+	statusCode, _ = serve(http.MethodDelete, "/api/user/keys/"+created.ID, "", "")
+	assert.Equal(t, http.StatusNoContent, statusCode)
+
+	statusCode, _ = serve(http.MethodPost, "/api/shorten", created.Key, `{"url":"https://filimonovvadim.t.me"}`)
+	assert.Equal(t, http.StatusUnauthorized, statusCode)
+}
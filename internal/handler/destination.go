@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/VadimFilimonov/urlshortener/internal/clicks"
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
+	"github.com/VadimFilimonov/urlshortener/internal/split"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	"github.com/VadimFilimonov/urlshortener/internal/targeting"
+	"github.com/VadimFilimonov/urlshortener/internal/useragent"
+)
+
+var errPathNotAllowed = errors.New("link does not accept a path suffix")
+
+// chooseDestination returns the URL of the first rule matching the visitor
+// of r. Otherwise a link with variants sends the visitor to one of them,
+// reported as variant, and any other link to originalURL. The visitor is
+// only classified when there are rules.
+func chooseDestination(w http.ResponseWriter, r *http.Request, shortenURL, originalURL string, options storage.Options, geo *geoip.DB) (destination, variant string) {
+	if len(options.Rules) > 0 {
+		visitor := targeting.Visitor{
+			Info:      useragent.Classify(r.UserAgent()),
+			Location:  geo.Lookup(clicks.ClientIP(r)),
+			Languages: targeting.ParseAcceptLanguage(r.Header.Get("Accept-Language")),
+			Time:      time.Now(),
+		}
+
+		destination, found := options.Rules.Match(visitor)
+		if found {
+			return destination, ""
+		}
+	}
+
+	if len(options.Variants) == 0 {
+		return originalURL, ""
+	}
+
+	chosen := pickVariant(w, r, shortenURL, options)
+
+	return chosen.URL, chosen.Name
+}
+
+// pickVariant chooses a variant at random. With StickyVariants the choice is
+// kept in a cookie scoped to the short URL and reused while the variant
+// exists.
+func pickVariant(w http.ResponseWriter, r *http.Request, shortenURL string, options storage.Options) split.Variant {
+	cookieName := "variant_" + shortenURL
+
+	if options.StickyVariants {
+		cookie, err := r.Cookie(cookieName)
+		if err == nil {
+			variant, found := options.Variants.Find(cookie.Value)
+			if found {
+				return variant
+			}
+		}
+	}
+
+	variant := options.Variants.Pick(rand.Intn)
+
+	if options.StickyVariants {
+		http.SetCookie(w, &http.Cookie{
+			Name:     cookieName,
+			Value:    variant.Name,
+			Path:     "/" + shortenURL,
+			MaxAge:   int((365 * 24 * time.Hour).Seconds()),
+			HttpOnly: true,
+		})
+	}
+
+	return variant
+}
+
+// buildDestination returns the URL a visitor is redirected to. With
+// PassQuery the query of the request is merged into destination, keeping
+// parameters destination already has. With PassPath the path after the
+// short code is appended to the destination path. UTM parameters of the
+// link are set last and win over the visitor's ones.
+func buildDestination(destination string, options storage.Options, r *http.Request) (string, error) {
+	suffix := chi.URLParam(r, "*")
+
+	if suffix != "" && !options.PassPath {
+		return "", errPathNotAllowed
+	}
+
+	incomingQuery := r.URL.Query()
+
+	if suffix == "" && (!options.PassQuery || len(incomingQuery) == 0) {
+		return options.UTM.Apply(destination)
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+
+	if suffix != "" {
+		u.Path = strings.TrimSuffix(u.Path, "/") + path.Clean("/"+suffix)
+		if strings.HasSuffix(suffix, "/") {
+			u.Path += "/"
+		}
+		u.RawPath = ""
+	}
+
+	if options.PassQuery {
+		query := u.Query()
+
+		for key, values := range incomingQuery {
+			if query.Has(key) {
+				continue
+			}
+			query[key] = values
+		}
+		u.RawQuery = query.Encode()
+	}
+
+	return options.UTM.Apply(u.String())
+}
@@ -0,0 +1,327 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/destpolicy"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	"github.com/VadimFilimonov/urlshortener/internal/utm"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
+)
+
+type LinkOutput struct {
+	ShortURL    string `json:"short_url"`
+	OriginalURL string `json:"original_url"`
+	OptionsInput
+}
+
+type RevisionOutput struct {
+	Revision    int       `json:"revision"`
+	OriginalURL string    `json:"original_url"`
+	ReplacedAt  time.Time `json:"replaced_at"`
+	OptionsInput
+}
+
+// NewUpdateUserURL changes the destination and options of a link owned by
+// the user. The body has the shape of ShortenInput and only the fields it
+// contains are changed, so {"url": "..."} keeps the options as they are.
+// The fields are validated up front and merged into the stored version by
+// data.Patch, so concurrent edits do not overwrite each other.
+func NewUpdateUserURL(data storage.Data, host string, validator *validation.Validator, policy *destpolicy.Policy, presets utm.Presets) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+		shortenURL := chi.URLParam(r, "id")
+
+		body, err := io.ReadAll(r.Body)
+		defer r.Body.Close()
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		patch, ok := newLinkPatch(w, body, presets, validator, policy)
+		if !ok {
+			return
+		}
+
+		var originalURL string
+		var options storage.Options
+
+		err = data.Patch(shortenURL, userIDCookieValue, func(currentURL string, currentOptions storage.Options) (string, storage.Options, error) {
+			originalURL, options = patch.apply(currentURL, currentOptions)
+			return originalURL, options, nil
+		})
+		if !writeUpdateError(w, err) {
+			return
+		}
+
+		writeLink(w, host, shortenURL, originalURL, options)
+	}
+}
+
+// linkPatch is an edit of a link validated on its own: the fields present in
+// the body and the link they describe.
+type linkPatch struct {
+	fields      map[string]bool
+	originalURL string
+	options     storage.Options
+	// utm are the utm_* fields as sent, campaign is the preset they are
+	// merged with.
+	utm         utm.Params
+	campaign    utm.Params
+	setPassword bool
+}
+
+// newLinkPatch parses body and validates the fields it contains. If they are
+// invalid, the error response is written and ok is false.
+func newLinkPatch(w http.ResponseWriter, body []byte, presets utm.Presets, validator *validation.Validator, policy *destpolicy.Policy) (patch linkPatch, ok bool) {
+	var input ShortenInput
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(body, &input)
+	if err == nil {
+		err = json.Unmarshal(body, &fields)
+	}
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return patch, false
+	}
+
+	// json.Unmarshal matches field names case-insensitively.
+	patch.fields = make(map[string]bool, len(fields))
+	for name := range fields {
+		patch.fields[strings.ToLower(name)] = true
+	}
+
+	if patch.has("url") {
+		patch.originalURL, ok = prepareURL(w, input.URL, validator, policy)
+		if !ok {
+			return patch, false
+		}
+	}
+
+	patch.options, ok = buildOptions(w, input.OptionsInput, presets, validator, policy)
+	if !ok {
+		return patch, false
+	}
+
+	patch.utm = utm.Params{Source: input.UTMSource, Medium: input.UTMMedium, Campaign: input.UTMCampaign}
+	patch.campaign = presets[input.Campaign]
+	patch.setPassword = input.Password != "" || input.RemovePassword
+
+	return patch, true
+}
+
+func (p linkPatch) has(field string) bool {
+	return p.fields[field]
+}
+
+// apply merges the patch into the current version of a link. Rules, variants
+// and tags are replaced as a whole, a campaign fills the UTM fields left
+// empty.
+func (p linkPatch) apply(originalURL string, options storage.Options) (string, storage.Options) {
+	if p.has("url") {
+		originalURL = p.originalURL
+	}
+
+	if p.has("redirect_type") {
+		options.RedirectType = p.options.RedirectType
+	}
+
+	if p.has("pass_query") {
+		options.PassQuery = p.options.PassQuery
+	}
+
+	if p.has("pass_path") {
+		options.PassPath = p.options.PassPath
+	}
+
+	if p.has("utm_source") {
+		options.UTM.Source = p.utm.Source
+	}
+
+	if p.has("utm_medium") {
+		options.UTM.Medium = p.utm.Medium
+	}
+
+	if p.has("utm_campaign") {
+		options.UTM.Campaign = p.utm.Campaign
+	}
+
+	if p.has("campaign") {
+		options.UTM = options.UTM.Merge(p.campaign)
+	}
+
+	if p.has("one_time") {
+		options.OneTime = p.options.OneTime
+	}
+
+	if p.has("activates_at") {
+		options.ActivatesAt = p.options.ActivatesAt
+	}
+
+	if p.has("fallback_url") {
+		options.FallbackURL = p.options.FallbackURL
+	}
+
+	if p.has("title") {
+		options.Title = p.options.Title
+	}
+
+	if p.has("force_preview") {
+		options.ForcePreview = p.options.ForcePreview
+	}
+
+	if p.has("tags") {
+		options.Tags = p.options.Tags
+	}
+
+	if p.has("folder") {
+		options.Folder = p.options.Folder
+	}
+
+	if p.has("rules") {
+		options.Rules = p.options.Rules
+	}
+
+	if p.has("variants") {
+		options.Variants = p.options.Variants
+	}
+
+	if p.has("sticky_variants") {
+		options.StickyVariants = p.options.StickyVariants
+	}
+
+	if p.setPassword {
+		options.PasswordHash = p.options.PasswordHash
+	}
+
+	return originalURL, options
+}
+
+// NewGetRevisions lists the replaced versions of a link, oldest first.
+func NewGetRevisions(data storage.Data) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+		shortenURL := chi.URLParam(r, "id")
+
+		revisions, err := data.GetRevisions(shortenURL, userIDCookieValue)
+
+		if errors.Is(err, storage.ErrURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		output := make([]RevisionOutput, len(revisions))
+		for i, revision := range revisions {
+			output[i] = RevisionOutput{
+				Revision:     revision.Number,
+				OriginalURL:  revision.OriginalURL,
+				ReplacedAt:   revision.ReplacedAt,
+				OptionsInput: inputFromOptions(revision.Options),
+			}
+		}
+
+		response, err := json.Marshal(output)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(response)
+	}
+}
+
+// NewRollback restores a revision of a link. The restored version is checked
+// against the current validation rules and destination policy, and the
+// version it replaces becomes a new revision, so a rollback can be undone.
+func NewRollback(data storage.Data, host string, validator *validation.Validator, policy *destpolicy.Policy) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+		shortenURL := chi.URLParam(r, "id")
+
+		number, err := strconv.Atoi(chi.URLParam(r, "revision"))
+		if err != nil {
+			http.Error(w, "revision must be a number", http.StatusBadRequest)
+			return
+		}
+
+		revisions, err := data.GetRevisions(shortenURL, userIDCookieValue)
+		if !writeUpdateError(w, err) {
+			return
+		}
+
+		if number < 1 || number > len(revisions) {
+			http.Error(w, fmt.Sprintf("revision %d not found", number), http.StatusNotFound)
+			return
+		}
+		revision := revisions[number-1]
+
+		originalURL, ok := prepareURL(w, revision.OriginalURL, validator, policy)
+		if !ok {
+			return
+		}
+
+		options, ok := buildOptions(w, inputFromOptions(revision.Options), nil, validator, policy)
+		if !ok {
+			return
+		}
+
+		err = data.Update(shortenURL, userIDCookieValue, originalURL, options)
+		if !writeUpdateError(w, err) {
+			return
+		}
+
+		writeLink(w, host, shortenURL, originalURL, options)
+	}
+}
+
+// writeUpdateError writes the response for a storage error of a link being
+// edited. It returns true if there is no error.
+func writeUpdateError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, storage.ErrURLNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, storage.ErrURLHasBeenDeleted), errors.Is(err, storage.ErrURLHasBeenConsumed):
+		http.Error(w, err.Error(), http.StatusGone)
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+
+	return false
+}
+
+func writeLink(w http.ResponseWriter, host, shortenURL, originalURL string, options storage.Options) {
+	response, err := json.Marshal(LinkOutput{
+		ShortURL:     fmt.Sprintf("%s/%s", host, shortenURL),
+		OriginalURL:  originalURL,
+		OptionsInput: inputFromOptions(options),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(response)
+}
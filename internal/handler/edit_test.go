@@ -0,0 +1,210 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	"github.com/VadimFilimonov/urlshortener/internal/targeting"
+	"github.com/VadimFilimonov/urlshortener/internal/utm"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
+)
+
+func TestEditUserURL(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	validator := validation.New(Host, validation.DefaultSchemes)
+	rules := targeting.Rules{{OS: []string{"iOS"}, URL: "https://apps.apple.com/app"}}
+	shortenURL, err := data.Add("https://filimonovvadim.t.me/typo", "user1", storage.Options{PassQuery: true, Rules: rules})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(auth.New(testSigner, nil, nil).Middleware)
+	router.Patch("/api/user/urls/{id}", NewUpdateUserURL(data, Host, validator, nil, nil))
+	router.Get("/api/user/urls/{id}/revisions", NewGetRevisions(data))
+	router.Post("/api/user/urls/{id}/revisions/{revision}/rollback", NewRollback(data, Host, validator, nil))
+
+	serve := func(method, target, userID, body string) *http.Response {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{Name: "userID", Value: testSigner.Sign(userID)})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		userID      string
+		body        string
+		statusCode  int
+		originalURL string
+		options     storage.Options
+	}{
+		{
+			name:       "Other user",
+			method:     http.MethodPatch,
+			target:     "/api/user/urls/" + shortenURL,
+			userID:     "user2",
+			body:       `{"url":"https://example.com"}`,
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "Invalid url is rejected before the link is looked up",
+			method:     http.MethodPatch,
+			target:     "/api/user/urls/" + shortenURL,
+			userID:     "user2",
+			body:       `{"url":"javascript:alert(1)"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Invalid url",
+			method:     http.MethodPatch,
+			target:     "/api/user/urls/" + shortenURL,
+			userID:     "user1",
+			body:       `{"url":"javascript:alert(1)"}`,
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:        "Options are kept",
+			method:      http.MethodPatch,
+			target:      "/api/user/urls/" + shortenURL,
+			userID:      "user1",
+			body:        `{"url":"https://filimonovvadim.t.me/fixed"}`,
+			statusCode:  http.StatusOK,
+			originalURL: "https://filimonovvadim.t.me/fixed",
+			options:     storage.Options{PassQuery: true, Rules: rules},
+		},
+		{
+			name:        "Options are changed",
+			method:      http.MethodPatch,
+			target:      "/api/user/urls/" + shortenURL,
+			userID:      "user1",
+			body:        `{"pass_query":false,"rules":null,"redirect_type":301}`,
+			statusCode:  http.StatusOK,
+			originalURL: "https://filimonovvadim.t.me/fixed",
+			options:     storage.Options{RedirectType: 301},
+		},
+		{
+			name:        "Rollback",
+			method:      http.MethodPost,
+			target:      "/api/user/urls/" + shortenURL + "/revisions/1/rollback",
+			userID:      "user1",
+			statusCode:  http.StatusOK,
+			originalURL: "https://filimonovvadim.t.me/typo",
+			options:     storage.Options{PassQuery: true, Rules: rules},
+		},
+		{
+			name:       "Unknown revision",
+			method:     http.MethodPost,
+			target:     "/api/user/urls/" + shortenURL + "/revisions/9/rollback",
+			userID:     "user1",
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:        "Only the fields sent are changed",
+			method:      http.MethodPatch,
+			target:      "/api/user/urls/" + shortenURL,
+			userID:      "user1",
+			body:        `{"title":"Docs","utm_source":"tg"}`,
+			statusCode:  http.StatusOK,
+			originalURL: "https://filimonovvadim.t.me/typo",
+			options:     storage.Options{PassQuery: true, Rules: rules, Title: "Docs", UTM: utm.Params{Source: "tg"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := serve(tt.method, tt.target, tt.userID, tt.body)
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+
+			if tt.statusCode != http.StatusOK {
+				return
+			}
+
+			item, err := data.Get(shortenURL)
+			require.NoError(t, err)
+			assert.Equal(t, tt.originalURL, item.OriginalURL)
+			assert.Equal(t, tt.options, item.Options)
+		})
+	}
+
+	result := serve(http.MethodGet, "/api/user/urls/"+shortenURL+"/revisions", "user1", "")
+	defer result.Body.Close()
+	require.Equal(t, http.StatusOK, result.StatusCode)
+
+	var revisions []RevisionOutput
+	err = json.NewDecoder(result.Body).Decode(&revisions)
+	require.NoError(t, err)
+	require.Len(t, revisions, 4)
+	assert.Equal(t, "https://filimonovvadim.t.me/typo", revisions[0].OriginalURL)
+	assert.Equal(t, 301, revisions[2].RedirectType)
+}
+
+func TestEditUserURLConcurrently(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	validator := validation.New(Host, validation.DefaultSchemes)
+	shortenURL, err := data.Add("https://filimonovvadim.t.me", "user1", storage.Options{})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Use(auth.New(testSigner, nil, nil).Middleware)
+	router.Patch("/api/user/urls/{id}", NewUpdateUserURL(data, Host, validator, nil, nil))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i += 1 {
+		body := fmt.Sprintf(`{"title":"title %d"}`, i)
+		if i%2 == 1 {
+			body = fmt.Sprintf(`{"folder":"folder %d"}`, i)
+		}
+
+		wg.Add(1)
+		go func(body string) {
+			defer wg.Done()
+			request := httptest.NewRequest(http.MethodPatch, "/api/user/urls/"+shortenURL, strings.NewReader(body))
+			request.AddCookie(&http.Cookie{Name: "userID", Value: testSigner.Sign("user1")})
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}(body)
+	}
+	wg.Wait()
+
+	item, err := data.Get(shortenURL)
+	require.NoError(t, err)
+	assert.NotEmpty(t, item.Title, "no edit overwrites another with a stale version")
+	assert.NotEmpty(t, item.Folder, "no edit overwrites another with a stale version")
+
+	revisions, err := data.GetRevisions(shortenURL, "user1")
+	require.NoError(t, err)
+	assert.Len(t, revisions, 20)
+}
+
+func TestEditConsumedURL(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	validator := validation.New(Host, validation.DefaultSchemes)
+	shortenURL, err := data.Add("https://filimonovvadim.t.me", "user1", storage.Options{OneTime: true})
+	require.NoError(t, err)
+	require.NoError(t, data.Consume(shortenURL))
+
+	router := chi.NewRouter()
+	router.Use(auth.New(testSigner, nil, nil).Middleware)
+	router.Patch("/api/user/urls/{id}", NewUpdateUserURL(data, Host, validator, nil, nil))
+
+	request := httptest.NewRequest(http.MethodPatch, "/api/user/urls/"+shortenURL, strings.NewReader(`{"title":"used"}`))
+	request.AddCookie(&http.Cookie{Name: "userID", Value: testSigner.Sign("user1")})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+
+	assert.Equal(t, http.StatusGone, w.Code)
+}
@@ -9,27 +9,42 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	_ "github.com/jackc/pgx/v5/stdlib"
 
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/clicks"
 	"github.com/VadimFilimonov/urlshortener/internal/constants"
+	"github.com/VadimFilimonov/urlshortener/internal/destpolicy"
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
+	"github.com/VadimFilimonov/urlshortener/internal/ratelimit"
 	"github.com/VadimFilimonov/urlshortener/internal/storage"
-	utils "github.com/VadimFilimonov/urlshortener/internal/utils/generateid"
+	"github.com/VadimFilimonov/urlshortener/internal/utm"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
 )
 
-func NewGet(data storage.Data, host string) func(http.ResponseWriter, *http.Request) {
+// NewGet redirects a visitor of a short URL. Protected links first ask for
+// the password, see checkPassword, and are answered with 303 See Other when
+// it comes from the form, so the password is not posted to the destination.
+// One-time links are consumed by the first redirect and answer 410 Gone
+// afterwards. Links which are not active yet are answered by serveInactive
+// with placeholder. Visitors asking for a preview, see previewSuffix, and
+// every visitor of a link with ForcePreview get the preview page instead of
+// the redirect.
+func NewGet(data storage.Data, host string, defaultRedirectType int, recorder *clicks.Recorder, geo *geoip.DB, attempts *ratelimit.Limiter, placeholder []byte) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		shortenURL := chi.URLParam(r, "shortenURL")
+		shortenURL, previewAsked, previewRefused := parsePreview(r, chi.URLParam(r, "shortenURL"))
 
 		if len(shortenURL) == 0 {
 			http.Error(w, "shortenURL param is missed", http.StatusBadRequest)
 			return
 		}
-		originalURL, err := data.Get(shortenURL)
+		item, err := data.Get(shortenURL)
 
-		if errors.Is(err, storage.ErrURLHasBeenDeleted) {
+		if errors.Is(err, storage.ErrURLHasBeenDeleted) || errors.Is(err, storage.ErrURLHasBeenConsumed) {
 			http.Error(w, err.Error(), http.StatusGone)
 			return
 		}
@@ -39,14 +54,83 @@ func NewGet(data storage.Data, host string) func(http.ResponseWriter, *http.Requ
 			return
 		}
 
-		w.Header().Set("Location", originalURL)
-		w.WriteHeader(http.StatusTemporaryRedirect)
+		if !item.IsActive(time.Now()) {
+			serveInactive(w, item.Options, placeholder)
+			return
+		}
+
+		if previewAsked || (item.ForcePreview && !previewRefused) {
+			destination, _ := chooseDestination(w, r, item.ShortenURL, item.OriginalURL, item.Options, geo)
+
+			destination, err = buildDestination(destination, item.Options, r)
+			if err != nil {
+				http.NotFound(w, r)
+				return
+			}
+
+			servePreview(w, previewData{
+				Title:       item.Title,
+				Destination: previewDestination(destination, item.PasswordHash != "" || item.OneTime),
+				CreatedAt:   item.CreatedAt,
+				ContinueURL: continueURL(r, host, item.ShortenURL, item.ForcePreview),
+			})
+			return
+		}
+
+		if item.PasswordHash != "" && !checkPassword(w, r, item.ShortenURL, item.PasswordHash, attempts) {
+			return
+		}
+
+		destination, variant := chooseDestination(w, r, item.ShortenURL, item.OriginalURL, item.Options, geo)
+
+		destination, err = buildDestination(destination, item.Options, r)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+
+		if item.OneTime {
+			err = data.Consume(item.ShortenURL)
+
+			if errors.Is(err, storage.ErrURLHasBeenConsumed) || errors.Is(err, storage.ErrURLHasBeenDeleted) {
+				http.Error(w, err.Error(), http.StatusGone)
+				return
+			}
+
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		redirectType := item.RedirectType
+		if redirectType == 0 {
+			redirectType = defaultRedirectType
+		}
+
+		if r.Method == http.MethodPost {
+			redirectType = http.StatusSeeOther
+		}
+
+		if item.PasswordHash != "" || item.OneTime {
+			w.Header().Set("Cache-Control", "private, no-store")
+		}
+
+		click := clicks.NewClick(r, item.ShortenURL, geo)
+		click.Variant = variant
+		recorder.Record(click)
+
+		w.Header().Set("Location", destination)
+		w.WriteHeader(redirectType)
 	}
 }
 
-func NewPost(data storage.Data, host string) func(http.ResponseWriter, *http.Request) {
+// NewPost shortens the URL sent as a plain text body, with options taken from
+// the query string. Password protected links can only be created through
+// the JSON endpoints.
+func NewPost(data storage.Data, host string, validator *validation.Validator, policy *destpolicy.Policy, presets utm.Presets) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userIDCookieValue := manageUserIDCookie(w, r)
+		userIDCookieValue := auth.UserID(r.Context())
 
 		body, err := io.ReadAll(r.Body)
 		defer r.Body.Close()
@@ -56,7 +140,23 @@ func NewPost(data storage.Data, host string) func(http.ResponseWriter, *http.Req
 			return
 		}
 
-		shortenURLPath, err := data.Add(string(body), userIDCookieValue)
+		originalURL, ok := prepareURL(w, string(body), validator, policy)
+		if !ok {
+			return
+		}
+
+		input, err := optionsFromQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		options, ok := buildOptions(w, input, presets, validator, policy)
+		if !ok {
+			return
+		}
+
+		shortenURLPath, err := data.Add(originalURL, userIDCookieValue, options)
 		shortenURL := fmt.Sprintf("%s/%s", host, shortenURLPath)
 
 		if errors.Is(err, constants.ErrURLAlreadyExists) {
@@ -74,17 +174,74 @@ func NewPost(data storage.Data, host string) func(http.ResponseWriter, *http.Req
 	}
 }
 
+type ErrorOutput struct {
+	Error  string `json:"error"`
+	Reason string `json:"reason,omitempty"`
+	Rule   string `json:"rule,omitempty"`
+}
+
+func writeJSONError(w http.ResponseWriter, statusCode int, output ErrorOutput) {
+	response, err := json.Marshal(output)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	w.Write(response)
+}
+
+// prepareURL normalizes rawURL and checks it against the destination
+// policy. If the URL is rejected, the error response is written and ok is
+// false.
+func prepareURL(w http.ResponseWriter, rawURL string, validator *validation.Validator, policy *destpolicy.Policy) (originalURL string, ok bool) {
+	originalURL, err := validator.Normalize(rawURL)
+
+	var validationErr *validation.Error
+	if errors.As(err, &validationErr) {
+		writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+			Error:  validationErr.Message,
+			Reason: validationErr.Reason,
+		})
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+
+	err = policy.Check(originalURL)
+
+	var policyErr *destpolicy.Error
+	if errors.As(err, &policyErr) {
+		writeJSONError(w, http.StatusForbidden, ErrorOutput{
+			Error:  policyErr.Error(),
+			Reason: "destination_denied",
+			Rule:   policyErr.Rule,
+		})
+		return "", false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+
+	return originalURL, true
+}
+
 type ShortenInput struct {
 	URL string `json:"url"`
+	OptionsInput
 }
 
 type ShortenOutput struct {
 	Result string `json:"result"`
 }
 
-func NewShorten(data storage.Data, host string) func(http.ResponseWriter, *http.Request) {
+func NewShorten(data storage.Data, host string, validator *validation.Validator, policy *destpolicy.Policy, presets utm.Presets) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userIDCookieValue := manageUserIDCookie(w, r)
+		userIDCookieValue := auth.UserID(r.Context())
 
 		body, err := io.ReadAll(r.Body)
 		defer r.Body.Close()
@@ -102,7 +259,17 @@ func NewShorten(data storage.Data, host string) func(http.ResponseWriter, *http.
 			return
 		}
 
-		shortenURLPath, errDataAdd := data.Add(requestBody.URL, userIDCookieValue)
+		originalURL, ok := prepareURL(w, requestBody.URL, validator, policy)
+		if !ok {
+			return
+		}
+
+		options, ok := buildOptions(w, requestBody.OptionsInput, presets, validator, policy)
+		if !ok {
+			return
+		}
+
+		shortenURLPath, errDataAdd := data.Add(originalURL, userIDCookieValue, options)
 		shortenURL := fmt.Sprintf("%s/%s", host, shortenURLPath)
 
 		responseJSON, err := json.Marshal(ShortenOutput{
@@ -134,6 +301,7 @@ func NewShorten(data storage.Data, host string) func(http.ResponseWriter, *http.
 type ShortenBatchInputItem struct {
 	CorrelationID string `json:"correlation_id"`
 	OriginalURL   string `json:"original_url"`
+	OptionsInput
 }
 
 type ShortenBatchOutputItem struct {
@@ -141,9 +309,9 @@ type ShortenBatchOutputItem struct {
 	ShortURL      string `json:"short_url"`
 }
 
-func NewShortenBatch(data storage.Data, host string) func(http.ResponseWriter, *http.Request) {
+func NewShortenBatch(data storage.Data, host string, validator *validation.Validator, policy *destpolicy.Policy, presets utm.Presets) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userIDCookieValue := manageUserIDCookie(w, r)
+		userIDCookieValue := auth.UserID(r.Context())
 
 		body, err := io.ReadAll(r.Body)
 		defer r.Body.Close()
@@ -161,13 +329,29 @@ func NewShortenBatch(data storage.Data, host string) func(http.ResponseWriter, *
 			return
 		}
 
+		originalURLs := make([]string, len(input))
+		options := make([]storage.Options, len(input))
+
+		for i, item := range input {
+			originalURL, ok := prepareURL(w, item.OriginalURL, validator, policy)
+			if !ok {
+				return
+			}
+			originalURLs[i] = originalURL
+
+			options[i], ok = buildOptions(w, item.OptionsInput, presets, validator, policy)
+			if !ok {
+				return
+			}
+		}
+
 		outputList := make([]ShortenBatchOutputItem, len(input))
 
 		for i, item := range input {
-			shortenURLPath, err := data.Add(item.OriginalURL, userIDCookieValue)
+			shortenURLPath, err := data.Add(originalURLs[i], userIDCookieValue, options[i])
 			shortenURL := fmt.Sprintf("%s/%s", host, shortenURLPath)
 
-			if err != nil {
+			if err != nil && !errors.Is(err, constants.ErrURLAlreadyExists) {
 				http.Error(w, err.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -192,15 +376,34 @@ func NewShortenBatch(data storage.Data, host string) func(http.ResponseWriter, *
 }
 
 type URLData = struct {
-	ShortenURL  string `json:"short_url"`
-	OriginalURL string `json:"original_url"`
+	ShortenURL  string   `json:"short_url"`
+	OriginalURL string   `json:"original_url"`
+	Title       string   `json:"title,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Folder      string   `json:"folder,omitempty"`
 }
 
+// NewGetUserUrls lists the links of the user. The q query parameter searches
+// the original URL, title and tags by word prefixes, every tag parameter
+// must be set on a link and folder must be its folder, an empty one selecting
+// links outside of folders.
 func NewGetUserUrls(data storage.Data, host string) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userIDCookieValue := manageUserIDCookie(w, r)
+		userIDCookieValue := auth.UserID(r.Context())
+		query := r.URL.Query()
+
+		filter := storage.Filter{Query: query.Get("q")}
 
-		items, err := data.GetItemsOfUser(userIDCookieValue)
+		for _, tag := range query["tag"] {
+			filter.Tags = append(filter.Tags, normalizeTag(tag))
+		}
+
+		if folder, ok := query["folder"]; ok {
+			value := strings.TrimSpace(folder[0])
+			filter.Folder = &value
+		}
+
+		items, err := data.SearchItemsOfUser(userIDCookieValue, filter)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
@@ -214,6 +417,9 @@ func NewGetUserUrls(data storage.Data, host string) func(http.ResponseWriter, *h
 			normalizedItems[index] = URLData{
 				ShortenURL:  fmt.Sprintf("%s/%s", host, item.ShortenURL),
 				OriginalURL: item.OriginalURL,
+				Title:       item.Title,
+				Tags:        item.Tags,
+				Folder:      item.Folder,
 			}
 		}
 		response, err := json.Marshal(normalizedItems)
@@ -228,7 +434,7 @@ func NewGetUserUrls(data storage.Data, host string) func(http.ResponseWriter, *h
 
 func NewDeleteUserUrls(data storage.Data) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		userIDCookieValue := manageUserIDCookie(w, r)
+		userIDCookieValue := auth.UserID(r.Context())
 		body, err := io.ReadAll(r.Body)
 		defer r.Body.Close()
 
@@ -257,8 +463,6 @@ func NewDeleteUserUrls(data storage.Data) func(http.ResponseWriter, *http.Reques
 
 func NewPing(DBPath string) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		manageUserIDCookie(w, r)
-
 		if DBPath == "" {
 			http.Error(w, "empty path to database", http.StatusInternalServerError)
 			return
@@ -282,18 +486,3 @@ func NewPing(DBPath string) func(http.ResponseWriter, *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	}
 }
-
-func manageUserIDCookie(w http.ResponseWriter, r *http.Request) string {
-	userIDCookie, ErrNoCookie := r.Cookie("userID")
-
-	if ErrNoCookie != nil {
-		userIDCookie = &http.Cookie{
-			Name:  "userID",
-			Value: utils.GenerateID(),
-		}
-	}
-
-	http.SetCookie(w, userIDCookie)
-
-	return userIDCookie.Value
-}
@@ -7,8 +7,16 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/constants"
+	"github.com/VadimFilimonov/urlshortener/internal/split"
 	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	"github.com/VadimFilimonov/urlshortener/internal/targeting"
+	"github.com/VadimFilimonov/urlshortener/internal/utm"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
+	"github.com/go-chi/chi/v5"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -17,6 +25,8 @@ const (
 	Host string = "http://localhost:8080"
 )
 
+var testSigner, _ = auth.NewSigner([]string{strings.Repeat("s", auth.MinSecretLength)})
+
 func TestNewGet(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -40,7 +50,7 @@ func TestNewGet(t *testing.T) {
 			body := strings.NewReader("")
 			request := httptest.NewRequest(http.MethodGet, tt.request, body)
 			w := httptest.NewRecorder()
-			h := http.HandlerFunc(NewGet(storage.NewMemory(), tt.request))
+			h := http.HandlerFunc(NewGet(storage.NewMemory(storage.DedupeGlobal), tt.request, constants.DefaultRedirectType, nil, nil, nil, nil))
 			h.ServeHTTP(w, request)
 
 			result := w.Result()
@@ -54,6 +64,281 @@ func TestNewGet(t *testing.T) {
 	}
 }
 
+func TestNewGetRedirectType(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	defaultPath, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{})
+	require.NoError(t, err)
+	permanentPath, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{RedirectType: http.StatusMovedPermanently})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", NewGet(data, Host, http.StatusFound, nil, nil, nil, nil))
+
+	tests := []struct {
+		name       string
+		path       string
+		statusCode int
+	}{
+		{
+			name:       "Server default",
+			path:       defaultPath,
+			statusCode: http.StatusFound,
+		},
+		{
+			name:       "Chosen on creation",
+			path:       permanentPath,
+			statusCode: http.StatusMovedPermanently,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s/%s", Host, tt.path), nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, "https://filimonovvadim.t.me", result.Header.Get("Location"))
+		})
+	}
+}
+
+func TestNewGetPassthrough(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	plainPath, err := data.Add("https://filimonovvadim.t.me/docs?lang=en", "user", storage.Options{})
+	require.NoError(t, err)
+	passPath, err := data.Add("https://filimonovvadim.t.me/docs?lang=en", "user", storage.Options{PassQuery: true, PassPath: true})
+	require.NoError(t, err)
+	taggedPath, err := data.Add("https://filimonovvadim.t.me/docs?lang=en", "user", storage.Options{PassQuery: true, UTM: utm.Params{Source: "newsletter"}})
+	require.NoError(t, err)
+
+	get := NewGet(data, Host, constants.DefaultRedirectType, nil, nil, nil, nil)
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", get)
+	router.Get("/{shortenURL}/*", get)
+
+	tests := []struct {
+		name       string
+		request    string
+		statusCode int
+		location   string
+	}{
+		{
+			name:       "Query is dropped by default",
+			request:    fmt.Sprintf("/%s?utm_source=x", plainPath),
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me/docs?lang=en",
+		},
+		{
+			name:       "Path suffix is not allowed by default",
+			request:    fmt.Sprintf("/%s/extra", plainPath),
+			statusCode: http.StatusNotFound,
+		},
+		{
+			name:       "Query is merged",
+			request:    fmt.Sprintf("/%s?utm_source=x&lang=ru", passPath),
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me/docs?lang=en&utm_source=x",
+		},
+		{
+			name:       "Path suffix is appended",
+			request:    fmt.Sprintf("/%s/extra/../page", passPath),
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me/docs/page?lang=en",
+		},
+		{
+			name:       "UTM parameters of the link win",
+			request:    fmt.Sprintf("/%s?utm_source=x&utm_medium=cpc", taggedPath),
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me/docs?lang=en&utm_medium=cpc&utm_source=newsletter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.request, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+		})
+	}
+}
+
+func TestNewGetTargeting(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	shortenURL, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{
+		Rules: targeting.Rules{
+			{OS: []string{"iOS"}, URL: "https://apps.apple.com/app"},
+			{OS: []string{"Android"}, URL: "https://play.google.com/store/apps"},
+			{Languages: []string{"ru"}, URL: "https://filimonovvadim.t.me/ru"},
+		},
+	})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", NewGet(data, Host, constants.DefaultRedirectType, nil, nil, nil, nil))
+
+	tests := []struct {
+		name           string
+		userAgent      string
+		acceptLanguage string
+		location       string
+	}{
+		{
+			name:      "iOS",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1",
+			location:  "https://apps.apple.com/app",
+		},
+		{
+			name:      "Android",
+			userAgent: "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			location:  "https://play.google.com/store/apps",
+		},
+		{
+			name:           "Language",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			acceptLanguage: "ru-RU,ru;q=0.9",
+			location:       "https://filimonovvadim.t.me/ru",
+		},
+		{
+			name:      "No rule matches",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			location:  "https://filimonovvadim.t.me",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/"+shortenURL, nil)
+			request.Header.Set("User-Agent", tt.userAgent)
+			request.Header.Set("Accept-Language", tt.acceptLanguage)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, http.StatusTemporaryRedirect, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+		})
+	}
+}
+
+func TestNewGetVariants(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	shortenURL, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{
+		Variants: split.Variants{
+			{Name: "a", URL: "https://filimonovvadim.t.me/a", Weight: 1},
+			{Name: "b", URL: "https://filimonovvadim.t.me/b", Weight: 1},
+		},
+		StickyVariants: true,
+	})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", NewGet(data, Host, constants.DefaultRedirectType, nil, nil, nil, nil))
+
+	request := httptest.NewRequest(http.MethodGet, "/"+shortenURL, nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+
+	result := w.Result()
+	defer result.Body.Close()
+	location := result.Header.Get("Location")
+	assert.Contains(t, []string{"https://filimonovvadim.t.me/a", "https://filimonovvadim.t.me/b"}, location)
+	require.Len(t, result.Cookies(), 1)
+
+	for i := 0; i < 10; i += 1 {
+		request := httptest.NewRequest(http.MethodGet, "/"+shortenURL, nil)
+		request.AddCookie(result.Cookies()[0])
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, location, w.Result().Header.Get("Location"), "sticky visitor must keep the variant")
+	}
+}
+
+func TestNewGetOneTime(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	shortenURL, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{OneTime: true})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", NewGet(data, Host, constants.DefaultRedirectType, nil, nil, nil, nil))
+
+	for _, statusCode := range []int{http.StatusTemporaryRedirect, http.StatusGone, http.StatusGone} {
+		request := httptest.NewRequest(http.MethodGet, "/"+shortenURL, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		result := w.Result()
+		result.Body.Close()
+		assert.Equal(t, statusCode, result.StatusCode)
+	}
+}
+
+func TestNewGetActivation(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	future := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Hour)
+	pending, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{ActivatesAt: &future})
+	require.NoError(t, err)
+	withFallback, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{ActivatesAt: &future, FallbackURL: "https://filimonovvadim.t.me/soon"})
+	require.NoError(t, err)
+	active, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{ActivatesAt: &past, FallbackURL: "https://filimonovvadim.t.me/soon"})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", NewGet(data, Host, constants.DefaultRedirectType, nil, nil, nil, []byte("soon")))
+
+	tests := []struct {
+		name       string
+		shortenURL string
+		statusCode int
+		location   string
+		body       string
+	}{
+		{
+			name:       "Placeholder",
+			shortenURL: pending,
+			statusCode: http.StatusNotFound,
+			body:       "soon",
+		},
+		{
+			name:       "Fallback",
+			shortenURL: withFallback,
+			statusCode: http.StatusFound,
+			location:   "https://filimonovvadim.t.me/soon",
+		},
+		{
+			name:       "Active",
+			shortenURL: active,
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/"+tt.shortenURL, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+
+			if tt.body != "" {
+				body, err := io.ReadAll(result.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tt.body, string(body))
+			}
+		})
+	}
+}
+
 func TestNewPost(t *testing.T) {
 	tests := []struct {
 		name       string
@@ -68,13 +353,37 @@ func TestNewPost(t *testing.T) {
 			body:       "https://filimonovvadim.t.me",
 			statusCode: http.StatusCreated,
 		},
+		{
+			name:       "Permanent redirect",
+			request:    fmt.Sprintf("%s/?redirect_type=308", Host),
+			body:       "https://filimonovvadim.t.me",
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "Unsupported redirect type",
+			request:    fmt.Sprintf("%s/?redirect_type=200", Host),
+			body:       "https://filimonovvadim.t.me",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Not a url",
+			request:    Host,
+			body:       "filimonovvadim",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Url of the shortener itself",
+			request:    Host,
+			body:       fmt.Sprintf("%s/abcdef", Host),
+			statusCode: http.StatusBadRequest,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			body := strings.NewReader(tt.body)
 			request := httptest.NewRequest(http.MethodPost, tt.request, body)
 			w := httptest.NewRecorder()
-			h := http.HandlerFunc(NewPost(storage.NewMemory(), tt.request))
+			h := http.HandlerFunc(NewPost(storage.NewMemory(storage.DedupeGlobal), tt.request, validation.New(Host, validation.DefaultSchemes), nil, nil))
 			h.ServeHTTP(w, request)
 
 			result := w.Result()
@@ -104,6 +413,66 @@ func TestNewShorten(t *testing.T) {
 			body:       "",
 			statusCode: http.StatusBadRequest,
 		},
+		{
+			name:       "Unknown campaign",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"campaign\":\"spring\"}",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Javascript url",
+			body:       "{\"url\":\"javascript:alert(1)\"}",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Targeting rules",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"rules\":[{\"os\":[\"iOS\"],\"url\":\"https://apps.apple.com/app\"}]}",
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "Javascript url in a rule",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"rules\":[{\"os\":[\"iOS\"],\"url\":\"javascript:alert(1)\"}]}",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Variants",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"variants\":[{\"name\":\"a\",\"url\":\"https://filimonovvadim.t.me/a\"},{\"name\":\"b\",\"url\":\"https://filimonovvadim.t.me/b\",\"weight\":3}]}",
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "Duplicate variant",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"variants\":[{\"name\":\"a\",\"url\":\"https://filimonovvadim.t.me/a\"},{\"name\":\"a\",\"url\":\"https://filimonovvadim.t.me/b\"}]}",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Password",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"password\":\"secret\"}",
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "Activation with fallback",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"activates_at\":\"2026-11-01T00:00:00Z\",\"fallback_url\":\"https://filimonovvadim.t.me/soon\"}",
+			statusCode: http.StatusCreated,
+		},
+		{
+			name:       "Invalid fallback url",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"activates_at\":\"2026-11-01T00:00:00Z\",\"fallback_url\":\"javascript:alert(1)\"}",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Too long fallback url",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"activates_at\":\"2026-11-01T00:00:00Z\",\"fallback_url\":\"https://filimonovvadim.t.me/" + strings.Repeat("a", 255) + "\"}",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Too long utm parameter",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"utm_source\":\"" + strings.Repeat("a", 256) + "\"}",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Rule without url",
+			body:       "{\"url\":\"https://filimonovvadim.t.me\",\"rules\":[{\"os\":[\"iOS\"]}]}",
+			statusCode: http.StatusBadRequest,
+		},
 	}
 
 	for _, tt := range tests {
@@ -111,7 +480,7 @@ func TestNewShorten(t *testing.T) {
 			body := strings.NewReader(tt.body)
 			request := httptest.NewRequest(http.MethodPost, fmt.Sprintf("%s/api/shorten", Host), body)
 			w := httptest.NewRecorder()
-			h := http.HandlerFunc(NewShorten(storage.NewMemory(), Host))
+			h := http.HandlerFunc(NewShorten(storage.NewMemory(storage.DedupeGlobal), Host, validation.New(Host, validation.DefaultSchemes), nil, nil))
 			h.ServeHTTP(w, request)
 
 			result := w.Result()
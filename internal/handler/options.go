@@ -0,0 +1,357 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/bcrypt"
+	"golang.org/x/exp/slices"
+
+	"github.com/VadimFilimonov/urlshortener/internal/constants"
+	"github.com/VadimFilimonov/urlshortener/internal/destpolicy"
+	"github.com/VadimFilimonov/urlshortener/internal/split"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	"github.com/VadimFilimonov/urlshortener/internal/targeting"
+	"github.com/VadimFilimonov/urlshortener/internal/utm"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
+)
+
+// OptionsInput are the link options accepted by every create endpoint. JSON
+// endpoints embed it into their input, NewPost reads it from the query
+// string.
+type OptionsInput struct {
+	RedirectType int    `json:"redirect_type,omitempty"`
+	PassQuery    bool   `json:"pass_query,omitempty"`
+	PassPath     bool   `json:"pass_path,omitempty"`
+	UTMSource    string `json:"utm_source,omitempty"`
+	UTMMedium    string `json:"utm_medium,omitempty"`
+	UTMCampaign  string `json:"utm_campaign,omitempty"`
+	OneTime      bool   `json:"one_time,omitempty"`
+	// Until ActivatesAt visitors are sent to FallbackURL or get the
+	// placeholder page.
+	ActivatesAt *time.Time `json:"activates_at,omitempty"`
+	FallbackURL string     `json:"fallback_url,omitempty"`
+	// Title is shown on the preview page.
+	Title        string `json:"title,omitempty"`
+	ForcePreview bool   `json:"force_preview,omitempty"`
+	// Tags are lowercased and deduplicated. NewPost takes them as a comma
+	// separated list.
+	Tags   []string `json:"tags,omitempty"`
+	Folder string   `json:"folder,omitempty"`
+	// Campaign names a server-side UTM preset. Explicit utm_* fields
+	// override the preset.
+	Campaign string `json:"campaign,omitempty"`
+	// Rules and Variants are only accepted by the JSON endpoints. A variant
+	// without a weight gets 1.
+	Rules          targeting.Rules `json:"rules,omitempty"`
+	Variants       split.Variants  `json:"variants,omitempty"`
+	StickyVariants bool            `json:"sticky_variants,omitempty"`
+	// Password protects the link. It is stored as a bcrypt hash and never
+	// returned, RemovePassword makes a protected link public again. Both are
+	// only accepted by the JSON endpoints, NewPost would have the password
+	// in the query string and so in access logs.
+	Password       string `json:"password,omitempty"`
+	RemovePassword bool   `json:"remove_password,omitempty"`
+	// passwordHash carries the hash of a stored link through rollbacks.
+	passwordHash string
+}
+
+const (
+	maxTitleLength  = 255
+	maxTags         = 20
+	maxTagLength    = 50
+	maxFolderLength = 255
+	maxUTMLength    = 255
+)
+
+// optionsFromQuery reads the options of NewPost. Rules, variants and the
+// password are left out, see OptionsInput.
+func optionsFromQuery(query url.Values) (OptionsInput, error) {
+	input := OptionsInput{
+		FallbackURL: query.Get("fallback_url"),
+		Title:       query.Get("title"),
+		Folder:      query.Get("folder"),
+		UTMSource:   query.Get("utm_source"),
+		UTMMedium:   query.Get("utm_medium"),
+		UTMCampaign: query.Get("utm_campaign"),
+		Campaign:    query.Get("campaign"),
+	}
+	var err error
+
+	if value := query.Get("redirect_type"); value != "" {
+		input.RedirectType, err = strconv.Atoi(value)
+		if err != nil {
+			return input, err
+		}
+	}
+
+	if value := query.Get("pass_query"); value != "" {
+		input.PassQuery, err = strconv.ParseBool(value)
+		if err != nil {
+			return input, err
+		}
+	}
+
+	if value := query.Get("pass_path"); value != "" {
+		input.PassPath, err = strconv.ParseBool(value)
+		if err != nil {
+			return input, err
+		}
+	}
+
+	if value := query.Get("one_time"); value != "" {
+		input.OneTime, err = strconv.ParseBool(value)
+		if err != nil {
+			return input, err
+		}
+	}
+
+	if value := query.Get("force_preview"); value != "" {
+		input.ForcePreview, err = strconv.ParseBool(value)
+		if err != nil {
+			return input, err
+		}
+	}
+
+	if value := query.Get("tags"); value != "" {
+		input.Tags = strings.Split(value, ",")
+	}
+
+	if value := query.Get("activates_at"); value != "" {
+		activatesAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			return input, fmt.Errorf("activates_at must be an RFC 3339 time: %w", err)
+		}
+		input.ActivatesAt = &activatesAt
+	}
+
+	return input, nil
+}
+
+// inputFromOptions is the inverse of buildOptions for a stored link, with
+// the campaign already resolved into the UTM fields.
+func inputFromOptions(options storage.Options) OptionsInput {
+	return OptionsInput{
+		RedirectType:   options.RedirectType,
+		PassQuery:      options.PassQuery,
+		PassPath:       options.PassPath,
+		OneTime:        options.OneTime,
+		ActivatesAt:    options.ActivatesAt,
+		FallbackURL:    options.FallbackURL,
+		Title:          options.Title,
+		ForcePreview:   options.ForcePreview,
+		Tags:           options.Tags,
+		Folder:         options.Folder,
+		UTMSource:      options.UTM.Source,
+		UTMMedium:      options.UTM.Medium,
+		UTMCampaign:    options.UTM.Campaign,
+		Rules:          options.Rules,
+		Variants:       options.Variants,
+		StickyVariants: options.StickyVariants,
+		passwordHash:   options.PasswordHash,
+	}
+}
+
+// buildOptions validates link options supplied by the client, resolves the
+// campaign preset and checks rule, variant and fallback destinations like
+// the original URL. Text is limited to the columns it is stored in. If
+// they are invalid, the error response is written and ok is false.
+func buildOptions(w http.ResponseWriter, input OptionsInput, presets utm.Presets, validator *validation.Validator, policy *destpolicy.Policy) (options storage.Options, ok bool) {
+	if utf8.RuneCountInString(input.Title) > maxTitleLength {
+		writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+			Error:  fmt.Sprintf("title must not be longer than %d characters", maxTitleLength),
+			Reason: "invalid_title",
+		})
+		return options, false
+	}
+
+	tags, err := normalizeTags(input.Tags)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+			Error:  err.Error(),
+			Reason: "invalid_tags",
+		})
+		return options, false
+	}
+
+	folder := strings.TrimSpace(input.Folder)
+	if utf8.RuneCountInString(folder) > maxFolderLength {
+		writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+			Error:  fmt.Sprintf("folder must not be longer than %d characters", maxFolderLength),
+			Reason: "invalid_folder",
+		})
+		return options, false
+	}
+
+	if input.RedirectType != 0 && !slices.Contains(constants.RedirectTypes, input.RedirectType) {
+		writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+			Error:  fmt.Sprintf("redirect type must be one of %v", constants.RedirectTypes),
+			Reason: "invalid_redirect_type",
+		})
+		return options, false
+	}
+
+	params := utm.Params{
+		Source:   input.UTMSource,
+		Medium:   input.UTMMedium,
+		Campaign: input.UTMCampaign,
+	}
+
+	if input.Campaign != "" {
+		preset, found := presets[input.Campaign]
+		if !found {
+			writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+				Error:  fmt.Sprintf("unknown campaign %q", input.Campaign),
+				Reason: "unknown_campaign",
+			})
+			return options, false
+		}
+		params = params.Merge(preset)
+	}
+
+	for _, value := range []string{params.Source, params.Medium, params.Campaign} {
+		if utf8.RuneCountInString(value) > maxUTMLength {
+			writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+				Error:  fmt.Sprintf("utm parameters must not be longer than %d characters", maxUTMLength),
+				Reason: "invalid_utm",
+			})
+			return options, false
+		}
+	}
+
+	rules := make(targeting.Rules, 0, len(input.Rules))
+
+	for _, rule := range input.Rules {
+		err := rule.Validate()
+		if err != nil {
+			writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+				Error:  err.Error(),
+				Reason: "invalid_rule",
+			})
+			return options, false
+		}
+
+		rule.URL, ok = prepareURL(w, rule.URL, validator, policy)
+		if !ok {
+			return options, false
+		}
+		rules = append(rules, rule)
+	}
+
+	if len(rules) == 0 {
+		rules = nil
+	}
+
+	variants := make(split.Variants, len(input.Variants))
+	copy(variants, input.Variants)
+
+	for i := range variants {
+		if variants[i].Weight == 0 {
+			variants[i].Weight = 1
+		}
+	}
+
+	err = variants.Validate()
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+			Error:  err.Error(),
+			Reason: "invalid_variants",
+		})
+		return options, false
+	}
+
+	for i := range variants {
+		variants[i].URL, ok = prepareURL(w, variants[i].URL, validator, policy)
+		if !ok {
+			return options, false
+		}
+	}
+
+	if len(variants) == 0 {
+		variants = nil
+	}
+
+	fallbackURL := input.FallbackURL
+	if fallbackURL != "" {
+		fallbackURL, ok = prepareURL(w, fallbackURL, validator, policy)
+		if !ok {
+			return options, false
+		}
+	}
+
+	passwordHash := input.passwordHash
+	if input.RemovePassword {
+		passwordHash = ""
+	}
+
+	if input.Password != "" {
+		if len(input.Password) > maxPasswordLength {
+			writeJSONError(w, http.StatusBadRequest, ErrorOutput{
+				Error:  fmt.Sprintf("password must not be longer than %d bytes", maxPasswordLength),
+				Reason: "invalid_password",
+			})
+			return options, false
+		}
+
+		hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return options, false
+		}
+		passwordHash = string(hash)
+	}
+
+	return storage.Options{
+		RedirectType:   input.RedirectType,
+		PassQuery:      input.PassQuery,
+		PassPath:       input.PassPath,
+		UTM:            params,
+		Rules:          rules,
+		Variants:       variants,
+		StickyVariants: input.StickyVariants,
+		PasswordHash:   passwordHash,
+		OneTime:        input.OneTime,
+		ActivatesAt:    input.ActivatesAt,
+		FallbackURL:    fallbackURL,
+		Title:          input.Title,
+		ForcePreview:   input.ForcePreview,
+		Tags:           tags,
+		Folder:         folder,
+	}, true
+}
+
+// normalizeTags trims and lowercases tags, drops duplicates and sorts them.
+func normalizeTags(input []string) ([]string, error) {
+	tags := make([]string, 0, len(input))
+
+	for _, tag := range input {
+		tag = normalizeTag(tag)
+
+		if tag == "" || strings.Contains(tag, ",") || utf8.RuneCountInString(tag) > maxTagLength {
+			return nil, fmt.Errorf("tag %q must be 1 to %d characters without commas", tag, maxTagLength)
+		}
+		tags = append(tags, tag)
+	}
+
+	slices.Sort(tags)
+	tags = slices.Compact(tags)
+
+	if len(tags) > maxTags {
+		return nil, fmt.Errorf("a link can have at most %d tags", maxTags)
+	}
+
+	if len(tags) == 0 {
+		return nil, nil
+	}
+
+	return tags, nil
+}
+
+func normalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimSpace(tag))
+}
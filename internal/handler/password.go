@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/VadimFilimonov/urlshortener/internal/clicks"
+	"github.com/VadimFilimonov/urlshortener/internal/ratelimit"
+)
+
+// PasswordHeader lets API clients open a protected link without the form.
+const PasswordHeader = "X-Link-Password"
+
+// bcrypt ignores everything after 72 bytes.
+const maxPasswordLength = 72
+
+var passwordForm = template.Must(template.New("password").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Protected link</title>
+</head>
+<body>
+<form method="post" action="{{.Action}}">
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<label>Password <input type="password" name="password" autofocus required></label>
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// checkPassword guards a protected link. The password is taken from
+// PasswordHeader or from the form posted to the short URL. Wrong attempts
+// are limited per link and client IP. Unless the password is right, the
+// response is written and false is returned.
+func checkPassword(w http.ResponseWriter, r *http.Request, shortenURL, passwordHash string, attempts *ratelimit.Limiter) bool {
+	password := r.Header.Get(PasswordHeader)
+	fromForm := false
+
+	if password == "" && r.Method == http.MethodPost {
+		password = r.PostFormValue("password")
+		fromForm = true
+	}
+
+	if password == "" {
+		writePasswordForm(w, r, http.StatusUnauthorized, "")
+		return false
+	}
+
+	key := shortenURL + " " + clicks.ClientIP(r)
+	allowed, retryAfter := attempts.Allow(key)
+	if !allowed {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+		http.Error(w, "too many password attempts", http.StatusTooManyRequests)
+		return false
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(passwordHash), []byte(password))
+	if err == nil {
+		attempts.Refund(key)
+		return true
+	}
+
+	if fromForm {
+		writePasswordForm(w, r, http.StatusForbidden, "Wrong password, try again.")
+	} else {
+		http.Error(w, "wrong password", http.StatusForbidden)
+	}
+
+	return false
+}
+
+func writePasswordForm(w http.ResponseWriter, r *http.Request, statusCode int, message string) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+
+	passwordForm.Execute(w, struct {
+		Action string
+		Error  string
+	}{
+		Action: r.URL.RequestURI(),
+		Error:  message,
+	})
+}
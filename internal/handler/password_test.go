@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/VadimFilimonov/urlshortener/internal/constants"
+	"github.com/VadimFilimonov/urlshortener/internal/ratelimit"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+func TestNewGetPassword(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	data := storage.NewMemory(storage.DedupeNone)
+	shortenURL, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{PasswordHash: string(hash)})
+	require.NoError(t, err)
+
+	get := NewGet(data, Host, constants.DefaultRedirectType, nil, nil, ratelimit.New(3, time.Hour), nil)
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", get)
+	router.Post("/{shortenURL}", get)
+
+	tests := []struct {
+		name       string
+		method     string
+		header     string
+		form       string
+		statusCode int
+		location   string
+	}{
+		{
+			name:       "Form is shown",
+			method:     http.MethodGet,
+			statusCode: http.StatusUnauthorized,
+		},
+		{
+			name:       "Wrong header",
+			method:     http.MethodGet,
+			header:     "guess",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "Right header",
+			method:     http.MethodGet,
+			header:     "secret",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me",
+		},
+		{
+			name:       "Right form",
+			method:     http.MethodPost,
+			form:       "secret",
+			statusCode: http.StatusSeeOther,
+			location:   "https://filimonovvadim.t.me",
+		},
+		{
+			name:       "Wrong form",
+			method:     http.MethodPost,
+			form:       "guess again",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "Wrong form once more",
+			method:     http.MethodPost,
+			form:       "and again",
+			statusCode: http.StatusForbidden,
+		},
+		{
+			name:       "Attempts are limited",
+			method:     http.MethodPost,
+			form:       "secret",
+			statusCode: http.StatusTooManyRequests,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var request *http.Request
+			if tt.method == http.MethodPost {
+				request = httptest.NewRequest(tt.method, "/"+shortenURL, strings.NewReader(url.Values{"password": {tt.form}}.Encode()))
+				request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			} else {
+				request = httptest.NewRequest(tt.method, "/"+shortenURL, nil)
+			}
+			if tt.header != "" {
+				request.Header.Set(PasswordHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+		})
+	}
+}
+
+func TestNewGetPasswordRightAttemptsAreNotLimited(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	data := storage.NewMemory(storage.DedupeNone)
+	shortenURL, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{PasswordHash: string(hash)})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", NewGet(data, Host, constants.DefaultRedirectType, nil, nil, ratelimit.New(5, 15*time.Minute), nil))
+
+	for i := 0; i < 6; i += 1 {
+		request := httptest.NewRequest(http.MethodGet, "/"+shortenURL, nil)
+		request.Header.Set(PasswordHeader, "secret")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		result := w.Result()
+		result.Body.Close()
+		assert.Equal(t, http.StatusTemporaryRedirect, result.StatusCode, "request %d", i+1)
+	}
+}
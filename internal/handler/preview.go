@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// previewSuffix after a short code asks for the preview page, as does the
+// preview=1 query parameter. preview=0 skips a forced preview.
+const previewSuffix = "+"
+
+var previewPage = template.Must(template.New("preview").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{if .Title}}{{.Title}}{{else}}Link preview{{end}}</title>
+</head>
+<body>
+{{if .Title}}<h1>{{.Title}}</h1>{{end}}
+<dl>
+<dt>Destination</dt>
+<dd>{{if .Destination}}{{.Destination}}{{else}}Shown after you continue.{{end}}</dd>
+{{if not .CreatedAt.IsZero}}<dt>Created</dt>
+<dd>{{.CreatedAt.Format "2 January 2006"}}</dd>{{end}}
+</dl>
+<p><a href="{{.ContinueURL}}" rel="noreferrer">Continue</a></p>
+</body>
+</html>
+`))
+
+type previewData struct {
+	Title       string
+	Destination string
+	CreatedAt   time.Time
+	ContinueURL string
+}
+
+// parsePreview strips the preview marks from the short code and the query of
+// r, which is passed on to the destination otherwise. It returns the short
+// code, whether the preview was asked for and whether it was refused.
+func parsePreview(r *http.Request, shortenURL string) (code string, asked, refused bool) {
+	code = strings.TrimSuffix(shortenURL, previewSuffix)
+	asked = code != shortenURL
+
+	query := r.URL.Query()
+	if query.Has("preview") {
+		asked = asked || query.Get("preview") == "1"
+		refused = query.Get("preview") == "0"
+
+		query.Del("preview")
+		r.URL.RawQuery = query.Encode()
+	}
+
+	return code, asked, refused
+}
+
+// continueURL leads from the preview to the redirect, keeping the path
+// suffix and the query of r. A forced preview is skipped with preview=0.
+func continueURL(r *http.Request, host, shortenURL string, forced bool) string {
+	continueURL := host + "/" + shortenURL
+
+	if suffix := chi.URLParam(r, "*"); suffix != "" {
+		continueURL += "/" + suffix
+	}
+
+	query := r.URL.Query()
+	if forced {
+		query.Set("preview", "0")
+	}
+
+	if len(query) > 0 {
+		continueURL += "?" + query.Encode()
+	}
+
+	return continueURL
+}
+
+func servePreview(w http.ResponseWriter, data previewData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	previewPage.Execute(w, data)
+}
+
+// previewDestination is the destination shown on the preview page. Links
+// behind a password or usable once keep it to themselves.
+func previewDestination(destination string, protected bool) string {
+	if protected {
+		return ""
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return ""
+	}
+
+	return u.Redacted()
+}
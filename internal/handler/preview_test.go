@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/constants"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+func TestNewGetPreview(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	plain, err := data.Add("https://filimonovvadim.t.me/docs", "user", storage.Options{Title: "<b>Docs</b>", PassQuery: true})
+	require.NoError(t, err)
+	forced, err := data.Add("https://filimonovvadim.t.me/forced", "user", storage.Options{ForcePreview: true})
+	require.NoError(t, err)
+	oneTime, err := data.Add("https://filimonovvadim.t.me/secret", "user", storage.Options{OneTime: true})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/{shortenURL}", NewGet(data, Host, constants.DefaultRedirectType, nil, nil, nil, nil))
+
+	tests := []struct {
+		name       string
+		request    string
+		statusCode int
+		location   string
+		contains   []string
+		excludes   []string
+	}{
+		{
+			name:       "Preview suffix",
+			request:    "/" + plain + "+",
+			statusCode: http.StatusOK,
+			contains:   []string{"https://filimonovvadim.t.me/docs", "&lt;b&gt;Docs&lt;/b&gt;", Host + "/" + plain},
+		},
+		{
+			name:       "Preview parameter is not passed on",
+			request:    "/" + plain + "?preview=1&lang=en",
+			statusCode: http.StatusOK,
+			contains:   []string{"https://filimonovvadim.t.me/docs?lang=en", Host + "/" + plain + "?lang=en"},
+		},
+		{
+			name:       "No preview",
+			request:    "/" + plain + "?lang=en",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me/docs?lang=en",
+		},
+		{
+			name:       "Forced preview",
+			request:    "/" + forced,
+			statusCode: http.StatusOK,
+			contains:   []string{Host + "/" + forced + "?preview=0"},
+		},
+		{
+			name:       "Forced preview is skipped",
+			request:    "/" + forced + "?preview=0",
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me/forced",
+		},
+		{
+			name:       "One-time destination is hidden",
+			request:    "/" + oneTime + "+",
+			statusCode: http.StatusOK,
+			excludes:   []string{"https://filimonovvadim.t.me/secret"},
+		},
+		{
+			name:       "Preview does not consume one-time link",
+			request:    "/" + oneTime,
+			statusCode: http.StatusTemporaryRedirect,
+			location:   "https://filimonovvadim.t.me/secret",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.request, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, tt.location, result.Header.Get("Location"))
+
+			body, err := io.ReadAll(result.Body)
+			require.NoError(t, err)
+			for _, text := range tt.contains {
+				assert.Contains(t, string(body), text)
+			}
+			for _, text := range tt.excludes {
+				assert.NotContains(t, string(body), text)
+			}
+		})
+	}
+}
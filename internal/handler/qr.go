@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/VadimFilimonov/urlshortener/internal/qr"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+// NewGetQR renders a QR code of a short URL. The query may set format (png or
+// svg), size, margin, level (L, M, Q or H) and the fg and bg colors.
+func NewGetQR(data storage.Data, host string) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		shortenURL := chi.URLParam(r, "shortenURL")
+
+		_, err := data.Get(shortenURL)
+
+		if errors.Is(err, storage.ErrURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if errors.Is(err, storage.ErrURLHasBeenDeleted) || errors.Is(err, storage.ErrURLHasBeenConsumed) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		options, err := qrOptionsFromQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		content := fmt.Sprintf("%s/%s", host, shortenURL)
+		var image []byte
+		var contentType string
+
+		switch format := r.URL.Query().Get("format"); format {
+		case "", "png":
+			image, err = qr.PNG(content, options)
+			contentType = "image/png"
+		case "svg":
+			image, err = qr.SVG(content, options)
+			contentType = "image/svg+xml"
+		default:
+			http.Error(w, fmt.Sprintf("unknown format %q, expected png or svg", format), http.StatusBadRequest)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		// Kept out of shared caches and only briefly, so a deleted link
+		// stops getting its QR code soon.
+		w.Header().Set("Cache-Control", "private, max-age=300")
+		w.Write(image)
+	}
+}
+
+func qrOptionsFromQuery(query url.Values) (qr.Options, error) {
+	options := qr.DefaultOptions()
+	var err error
+
+	if value := query.Get("size"); value != "" {
+		options.Size, err = strconv.Atoi(value)
+		if err != nil {
+			return options, fmt.Errorf("size must be a number: %w", err)
+		}
+	}
+
+	if value := query.Get("margin"); value != "" {
+		options.Margin, err = strconv.Atoi(value)
+		if err != nil {
+			return options, fmt.Errorf("margin must be a number: %w", err)
+		}
+	}
+
+	if value := query.Get("level"); value != "" {
+		options.Level, err = qr.ParseLevel(value)
+		if err != nil {
+			return options, err
+		}
+	}
+
+	if value := query.Get("fg"); value != "" {
+		options.Foreground, err = qr.ParseColor(value)
+		if err != nil {
+			return options, err
+		}
+	}
+
+	if value := query.Get("bg"); value != "" {
+		options.Background, err = qr.ParseColor(value)
+		if err != nil {
+			return options, err
+		}
+	}
+
+	return options, options.Validate()
+}
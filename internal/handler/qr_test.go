@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+func TestNewGetQR(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	shortenURL, err := data.Add("https://filimonovvadim.t.me", "user", storage.Options{})
+	require.NoError(t, err)
+
+	router := chi.NewRouter()
+	router.Get("/api/qr/{shortenURL}", NewGetQR(data, Host))
+
+	tests := []struct {
+		name        string
+		request     string
+		statusCode  int
+		contentType string
+	}{
+		{
+			name:        "PNG by default",
+			request:     "/api/qr/" + shortenURL,
+			statusCode:  http.StatusOK,
+			contentType: "image/png",
+		},
+		{
+			name:        "SVG with options",
+			request:     "/api/qr/" + shortenURL + "?format=svg&size=512&margin=2&level=H&fg=%23336699&bg=ffffff",
+			statusCode:  http.StatusOK,
+			contentType: "image/svg+xml",
+		},
+		{
+			name:       "Size out of range",
+			request:    "/api/qr/" + shortenURL + "?size=10000",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown format",
+			request:    "/api/qr/" + shortenURL + "?format=gif",
+			statusCode: http.StatusBadRequest,
+		},
+		{
+			name:       "Unknown link",
+			request:    "/api/qr/unknown",
+			statusCode: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, tt.request, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, request)
+
+			result := w.Result()
+			defer result.Body.Close()
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+
+			if tt.contentType != "" {
+				assert.Equal(t, tt.contentType, result.Header.Get("Content-Type"))
+				assert.Equal(t, "private, max-age=300", result.Header.Get("Cache-Control"))
+			}
+		})
+	}
+}
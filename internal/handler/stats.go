@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+// NewGetStats reports clicks of a link to its owner. Bot clicks are counted
+// separately unless the include_bots=true query parameter is passed.
+func NewGetStats(data storage.Data) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+		shortenURL := chi.URLParam(r, "id")
+
+		stats, err := data.GetStats(shortenURL, userIDCookieValue)
+
+		if errors.Is(err, storage.ErrURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if r.URL.Query().Get("include_bots") == "true" {
+			stats = stats.WithBots()
+		}
+
+		response, err := json.Marshal(stats)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(response)
+	}
+}
+
+// maxClicksPage is the default and largest number of clicks per page.
+const maxClicksPage = 1000
+
+// NewGetClicks lists click events of a link to its owner, optionally
+// between the from and to times. At most limit events are returned; when
+// there are more, the Link header points to the next page.
+func NewGetClicks(data storage.Data) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+		shortenURL := chi.URLParam(r, "id")
+
+		from, err := parseTimeParam(r, "from")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		to, err := parseTimeParam(r, "to")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		query := storage.ClickQuery{From: from, To: to, Limit: maxClicksPage}
+
+		if value := r.URL.Query().Get("limit"); value != "" {
+			query.Limit, err = strconv.Atoi(value)
+			if err != nil || query.Limit < 1 || query.Limit > maxClicksPage {
+				http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxClicksPage), http.StatusBadRequest)
+				return
+			}
+		}
+
+		if value := r.URL.Query().Get("cursor"); value != "" {
+			query.Cursor, err = strconv.ParseInt(value, 10, 64)
+			if err != nil || query.Cursor < 0 {
+				http.Error(w, "malformed cursor", http.StatusBadRequest)
+				return
+			}
+		}
+
+		page, err := data.GetClicks(shortenURL, userIDCookieValue, query)
+
+		if errors.Is(err, storage.ErrURLNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response, err := json.Marshal(page.Clicks)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if page.Next != 0 {
+			next := *r.URL
+			values := next.Query()
+			values.Set("cursor", strconv.FormatInt(page.Next, 10))
+			next.RawQuery = values.Encode()
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"next\"", next.RequestURI()))
+		}
+
+		w.Header().Add("Content-Type", "application/json")
+		w.Write(response)
+	}
+}
+
+// parseTimeParam reads an optional RFC 3339 time from the query string.
+func parseTimeParam(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	parsedTime, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 time: %w", name, err)
+	}
+
+	return parsedTime, nil
+}
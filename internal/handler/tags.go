@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+)
+
+type TagOutput struct {
+	Tag   string `json:"tag"`
+	Count int    `json:"count"`
+}
+
+type FolderOutput struct {
+	Folder string `json:"folder"`
+	Count  int    `json:"count"`
+}
+
+// NewGetUserTags lists the tags of the user's links with the number of links
+// having each of them, sorted by tag.
+func NewGetUserTags(data storage.Data) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+
+		items, err := data.GetItemsOfUser(userIDCookieValue)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		counts := map[string]int{}
+		for _, item := range items {
+			for _, tag := range item.Tags {
+				counts[tag]++
+			}
+		}
+
+		output := make([]TagOutput, 0, len(counts))
+		for tag, count := range counts {
+			output = append(output, TagOutput{Tag: tag, Count: count})
+		}
+		slices.SortFunc(output, func(a, b TagOutput) bool {
+			return a.Tag < b.Tag
+		})
+
+		writeList(w, output)
+	}
+}
+
+// NewGetUserFolders lists the folders of the user's links with the number of
+// links in each, sorted by name. Links outside of folders are not counted.
+func NewGetUserFolders(data storage.Data) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userIDCookieValue := auth.UserID(r.Context())
+
+		items, err := data.GetItemsOfUser(userIDCookieValue)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		counts := map[string]int{}
+		for _, item := range items {
+			if item.Folder != "" {
+				counts[item.Folder]++
+			}
+		}
+
+		output := make([]FolderOutput, 0, len(counts))
+		for folder, count := range counts {
+			output = append(output, FolderOutput{Folder: folder, Count: count})
+		}
+		slices.SortFunc(output, func(a, b FolderOutput) bool {
+			return a.Folder < b.Folder
+		})
+
+		writeList(w, output)
+	}
+}
+
+// writeList writes list as JSON, or 204 No Content if it is empty, like
+// NewGetUserUrls does.
+func writeList[T any](w http.ResponseWriter, list []T) {
+	if len(list) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	response, err := json.Marshal(list)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Add("Content-Type", "application/json")
+	w.Write(response)
+}
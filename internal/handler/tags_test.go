@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/storage"
+	"github.com/VadimFilimonov/urlshortener/internal/validation"
+)
+
+func TestTagsAndFolders(t *testing.T) {
+	data := storage.NewMemory(storage.DedupeNone)
+	validator := validation.New(Host, validation.DefaultSchemes)
+
+	router := chi.NewRouter()
+	router.Use(auth.New(testSigner, nil, nil).Middleware)
+	router.Post("/api/shorten", NewShorten(data, Host, validator, nil, nil))
+	router.Get("/api/user/urls", NewGetUserUrls(data, Host))
+	router.Get("/api/user/tags", NewGetUserTags(data))
+	router.Get("/api/user/folders", NewGetUserFolders(data))
+
+	serve := func(method, target, body string) (int, string) {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request.AddCookie(&http.Cookie{Name: "userID", Value: testSigner.Sign("user1")})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+
+		result := w.Result()
+		defer result.Body.Close()
+		response, err := io.ReadAll(result.Body)
+		require.NoError(t, err)
+
+		return result.StatusCode, string(response)
+	}
+
+	statusCode, _ := serve(http.MethodPost, "/api/shorten", `{"url":"https://example.com/go-tips","title":"Concurrency","tags":[" Go ","talks","go"],"folder":"blog"}`)
+	require.Equal(t, http.StatusCreated, statusCode)
+	statusCode, _ = serve(http.MethodPost, "/api/shorten", `{"url":"https://example.org/rust","tags":["rust"]}`)
+	require.Equal(t, http.StatusCreated, statusCode)
+
+	statusCode, response := serve(http.MethodPost, "/api/shorten", `{"url":"https://example.org","tags":["a,b"]}`)
+	assert.Equal(t, http.StatusBadRequest, statusCode)
+	assert.Contains(t, response, "invalid_tags")
+
+	tests := []struct {
+		name       string
+		target     string
+		statusCode int
+		urls       []string
+	}{
+		{
+			name:       "All links",
+			target:     "/api/user/urls",
+			statusCode: http.StatusOK,
+			urls:       []string{"https://example.com/go-tips", "https://example.org/rust"},
+		},
+		{
+			name:       "Search",
+			target:     "/api/user/urls?q=concurr",
+			statusCode: http.StatusOK,
+			urls:       []string{"https://example.com/go-tips"},
+		},
+		{
+			name:       "Tag",
+			target:     "/api/user/urls?tag=GO&tag=talks",
+			statusCode: http.StatusOK,
+			urls:       []string{"https://example.com/go-tips"},
+		},
+		{
+			name:       "Outside of folders",
+			target:     "/api/user/urls?folder=",
+			statusCode: http.StatusOK,
+			urls:       []string{"https://example.org/rust"},
+		},
+		{
+			name:       "Nothing found",
+			target:     "/api/user/urls?q=python",
+			statusCode: http.StatusNoContent,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusCode, response := serve(http.MethodGet, tt.target, "")
+			assert.Equal(t, tt.statusCode, statusCode)
+
+			if tt.urls == nil {
+				return
+			}
+
+			var links []URLData
+			require.NoError(t, json.Unmarshal([]byte(response), &links))
+
+			urls := make([]string, len(links))
+			for i, link := range links {
+				urls[i] = link.OriginalURL
+			}
+			assert.ElementsMatch(t, tt.urls, urls)
+		})
+	}
+
+	statusCode, response = serve(http.MethodGet, "/api/user/tags", "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.JSONEq(t, `[{"tag":"go","count":1},{"tag":"rust","count":1},{"tag":"talks","count":1}]`, response)
+
+	statusCode, response = serve(http.MethodGet, "/api/user/folders", "")
+	assert.Equal(t, http.StatusOK, statusCode)
+	assert.JSONEq(t, `[{"folder":"blog","count":1}]`, response)
+}
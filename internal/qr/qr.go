@@ -0,0 +1,186 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"strconv"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+const (
+	MinSize   = 64
+	MaxSize   = 2048
+	MaxMargin = 16
+)
+
+// Options of a rendered code. Size is the width and height in pixels for PNG
+// and in user units for SVG, Margin is the quiet zone in modules.
+type Options struct {
+	Size       int
+	Margin     int
+	Level      qrcode.RecoveryLevel
+	Foreground color.RGBA
+	Background color.RGBA
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Size:       256,
+		Margin:     4,
+		Level:      qrcode.Medium,
+		Foreground: color.RGBA{A: 0xff},
+		Background: color.RGBA{R: 0xff, G: 0xff, B: 0xff, A: 0xff},
+	}
+}
+
+func (o Options) Validate() error {
+	if o.Size < MinSize || o.Size > MaxSize {
+		return fmt.Errorf("size must be between %d and %d", MinSize, MaxSize)
+	}
+
+	if o.Margin < 0 || o.Margin > MaxMargin {
+		return fmt.Errorf("margin must be between 0 and %d", MaxMargin)
+	}
+
+	return nil
+}
+
+// ParseLevel reads an error correction level: L, M, Q or H.
+func ParseLevel(value string) (qrcode.RecoveryLevel, error) {
+	switch strings.ToUpper(value) {
+	case "L":
+		return qrcode.Low, nil
+	case "M":
+		return qrcode.Medium, nil
+	case "Q":
+		return qrcode.High, nil
+	case "H":
+		return qrcode.Highest, nil
+	default:
+		return 0, fmt.Errorf("unknown error correction level %q, expected L, M, Q or H", value)
+	}
+}
+
+// ParseColor reads a color as RRGGBB hex digits, the leading # is optional.
+func ParseColor(value string) (color.RGBA, error) {
+	value = strings.TrimPrefix(value, "#")
+
+	if len(value) != 6 {
+		return color.RGBA{}, fmt.Errorf("color %q must be 6 hex digits", value)
+	}
+
+	rgb, err := strconv.ParseUint(value, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("color %q must be 6 hex digits", value)
+	}
+
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 0xff}, nil
+}
+
+// bitmap returns the modules of the code for content, true for dark ones,
+// with the quiet zone of options.Margin around.
+func bitmap(content string, options Options) ([][]bool, error) {
+	code, err := qrcode.New(content, options.Level)
+	if err != nil {
+		return nil, err
+	}
+	code.DisableBorder = true
+
+	modules := code.Bitmap()
+	size := len(modules) + 2*options.Margin
+	result := make([][]bool, size)
+
+	for y := range result {
+		result[y] = make([]bool, size)
+	}
+
+	for y, row := range modules {
+		copy(result[y+options.Margin][options.Margin:], row)
+	}
+
+	return result, nil
+}
+
+// PNG renders the code as an image of options.Size pixels. Modules are whole
+// pixels, what does not divide evenly is added to the margin.
+func PNG(content string, options Options) ([]byte, error) {
+	modules, err := bitmap(content, options)
+	if err != nil {
+		return nil, err
+	}
+
+	scale := options.Size / len(modules)
+	if scale == 0 {
+		return nil, errors.New("size is too small for the content")
+	}
+	offset := (options.Size - scale*len(modules)) / 2
+
+	palette := color.Palette{options.Background, options.Foreground}
+	img := image.NewPaletted(image.Rect(0, 0, options.Size, options.Size), palette)
+
+	for y, row := range modules {
+		for x, dark := range row {
+			if !dark {
+				continue
+			}
+
+			for dy := 0; dy < scale; dy += 1 {
+				for dx := 0; dx < scale; dx += 1 {
+					img.SetColorIndex(offset+x*scale+dx, offset+y*scale+dy, 1)
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err = png.Encode(&buf, img)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// SVG renders the code as a single path scaled to options.Size.
+func SVG(content string, options Options) ([]byte, error) {
+	modules, err := bitmap(content, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+
+	fmt.Fprintf(&buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`,
+		options.Size, options.Size, len(modules), len(modules))
+	fmt.Fprintf(&buf, `<rect width="100%%" height="100%%" fill="%s"/>`, hex(options.Background))
+	fmt.Fprintf(&buf, `<path fill="%s" d="`, hex(options.Foreground))
+
+	for y, row := range modules {
+		for x := 0; x < len(row); x += 1 {
+			if !row[x] {
+				continue
+			}
+
+			start := x
+			for x < len(row) && row[x] {
+				x += 1
+			}
+			fmt.Fprintf(&buf, "M%d %dh%dv1h-%dz", start, y, x-start, x-start)
+		}
+	}
+
+	buf.WriteString(`"/></svg>`)
+
+	return buf.Bytes(), nil
+}
+
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,63 @@
+package qr
+
+import (
+	"bytes"
+	"image/color"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPNG(t *testing.T) {
+	options := DefaultOptions()
+	options.Size = 300
+	options.Foreground = color.RGBA{R: 0x12, G: 0x34, B: 0x56, A: 0xff}
+
+	data, err := PNG("http://localhost:8080/abcdef", options)
+	require.NoError(t, err)
+
+	img, err := png.Decode(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 300, img.Bounds().Dx())
+	assert.Equal(t, 300, img.Bounds().Dy())
+
+	modules, err := bitmap("http://localhost:8080/abcdef", options)
+	require.NoError(t, err)
+	scale := options.Size / len(modules)
+	offset := (options.Size - scale*len(modules)) / 2
+
+	assert.Equal(t, color.RGBAModel.Convert(options.Background), color.RGBAModel.Convert(img.At(offset, offset)), "margin")
+	corner := offset + options.Margin*scale
+	assert.Equal(t, color.RGBAModel.Convert(options.Foreground), color.RGBAModel.Convert(img.At(corner, corner)), "finder pattern")
+}
+
+func TestSVG(t *testing.T) {
+	options := DefaultOptions()
+	options.Margin = 0
+
+	data, err := SVG("http://localhost:8080/abcdef", options)
+	require.NoError(t, err)
+
+	svg := string(data)
+	assert.True(t, strings.HasPrefix(svg, "<svg "))
+	assert.Contains(t, svg, `width="256" height="256"`)
+	assert.Contains(t, svg, `fill="#000000" d="M0 0h7v1h-7z`, "finder pattern starts at the corner")
+}
+
+func TestParse(t *testing.T) {
+	c, err := ParseColor("#ff8000")
+	require.NoError(t, err)
+	assert.Equal(t, color.RGBA{R: 0xff, G: 0x80, A: 0xff}, c)
+
+	_, err = ParseColor("red")
+	assert.Error(t, err)
+
+	_, err = ParseLevel("H")
+	assert.NoError(t, err)
+
+	_, err = ParseLevel("X")
+	assert.Error(t, err)
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+	"github.com/VadimFilimonov/urlshortener/internal/clicks"
+)
+
+// ByIP keys requests by the client IP. Behind a load balancer that is the
+// IP of the balancer for every request, unless RealIP is configured
+// with the header it reports the client in.
+func ByIP(r *http.Request) string {
+	return "ip " + clicks.ClientIP(r)
+}
+
+// ByUser keys requests by the user auth.Middleware identified them with.
+// Requests that just got a new user are keyed by the client IP, or a client
+// could dodge the limit by dropping its cookie.
+func ByUser(r *http.Request) string {
+	if !auth.Identified(r.Context()) {
+		return ByIP(r)
+	}
+
+	return "user " + auth.UserID(r.Context())
+}
+
+// Middleware answers requests whose key is out of tokens with 429 Too Many
+// Requests and a Retry-After header. A nil store limits nothing. When the
+// store fails the request is let through: an outage of the limiter should
+// not take the service down with it.
+func Middleware(store Store, key func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if store == nil {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ok, retryAfter, err := store.Take(key(r))
+
+			if err != nil {
+				log.Printf("rate limit: %v", err)
+			}
+
+			if err == nil && !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
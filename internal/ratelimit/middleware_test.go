@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/auth"
+)
+
+type failingStore struct{}
+
+func (failingStore) Take(key string) (bool, time.Duration, error) {
+	return false, 0, errors.New("database is down")
+}
+
+func TestMiddleware(t *testing.T) {
+	signer, err := auth.NewSigner([]string{strings.Repeat("s", auth.MinSecretLength)})
+	require.NoError(t, err)
+
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	limiter := New(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	router := chi.NewRouter()
+	router.With(auth.New(signer, nil, nil).Middleware, Middleware(limiter, ByUser)).Post("/", ok)
+	router.With(Middleware(failingStore{}, ByIP)).Get("/failing", ok)
+	router.With(Middleware(nil, ByIP)).Get("/off", ok)
+
+	serve := func(method, target, remoteAddr, userID string) *http.Response {
+		request := httptest.NewRequest(method, target, nil)
+		request.RemoteAddr = remoteAddr
+		if userID != "" {
+			request.AddCookie(&http.Cookie{Name: auth.CookieName, Value: signer.Sign(userID)})
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w.Result()
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		userID     string
+		statusCode int
+		retryAfter string
+	}{
+		{name: "first request of a user", remoteAddr: "192.0.2.1:1234", userID: "user1", statusCode: http.StatusOK},
+		{name: "second request of the user from another IP", remoteAddr: "192.0.2.2:1234", userID: "user1", statusCode: http.StatusOK},
+		{name: "user out of tokens", remoteAddr: "192.0.2.3:1234", userID: "user1", statusCode: http.StatusTooManyRequests, retryAfter: "31"},
+		{name: "another user from the same IP", remoteAddr: "192.0.2.1:1234", userID: "user2", statusCode: http.StatusOK},
+		{name: "new user is limited by IP", remoteAddr: "192.0.2.9:1234", statusCode: http.StatusOK},
+		{name: "second new user from the IP", remoteAddr: "192.0.2.9:5678", statusCode: http.StatusOK},
+		{name: "dropping the cookie does not dodge the limit", remoteAddr: "192.0.2.9:1234", statusCode: http.StatusTooManyRequests, retryAfter: "31"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := serve(http.MethodPost, "/", tt.remoteAddr, tt.userID)
+			defer result.Body.Close()
+
+			assert.Equal(t, tt.statusCode, result.StatusCode)
+			assert.Equal(t, tt.retryAfter, result.Header.Get("Retry-After"))
+		})
+	}
+
+	now = now.Add(30 * time.Second)
+	result := serve(http.MethodPost, "/", "192.0.2.3:1234", "user1")
+	defer result.Body.Close()
+	assert.Equal(t, http.StatusOK, result.StatusCode, "the user gets a token back after 30 seconds")
+
+	for _, target := range []string{"/failing", "/off"} {
+		result := serve(http.MethodGet, target, "192.0.2.1:1234", "")
+		defer result.Body.Close()
+		assert.Equal(t, http.StatusOK, result.StatusCode, target)
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// Postgres keeps the buckets in the rate_limits table, so instances behind a
+// load balancer share them. Keys are prefixed with name to keep the buckets
+// of limits apart. Times come from the database, so the clocks of the
+// instances do not matter.
+type Postgres struct {
+	rate
+	db   *sql.DB
+	name string
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func NewPostgres(db *sql.DB, name string, limit int, period time.Duration) *Postgres {
+	return &Postgres{
+		rate: rate{limit: limit, period: period},
+		db:   db,
+		name: name,
+	}
+}
+
+// Take takes a token of key. The row of the bucket is locked meanwhile, so
+// concurrent requests of the key wait for each other.
+func (p *Postgres) Take(key string) (ok bool, retryAfter time.Duration, err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	key = p.name + " " + key
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, 0, err
+	}
+	defer tx.Rollback()
+
+	var now time.Time
+	err = tx.QueryRowContext(ctx, "SELECT now()").Scan(&now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	// The row of a new key is created first, so that two first requests of
+	// it lock the same row instead of both starting with a full bucket.
+	_, err = tx.ExecContext(ctx, "INSERT INTO rate_limits (key, tokens, updated_at) VALUES ($1, $2, $3) ON CONFLICT (key) DO NOTHING",
+		key, float64(p.limit), now)
+	if err != nil {
+		return false, 0, err
+	}
+
+	var b bucket
+	err = tx.QueryRowContext(ctx, "SELECT tokens, updated_at FROM rate_limits WHERE key = $1 FOR UPDATE", key).
+		Scan(&b.tokens, &b.last)
+	if err != nil {
+		return false, 0, err
+	}
+
+	ok, retryAfter = p.take(&b, now)
+
+	_, err = tx.ExecContext(ctx, "UPDATE rate_limits SET tokens = $2, updated_at = $3 WHERE key = $1", key, b.tokens, b.last)
+	if err != nil {
+		return false, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, 0, err
+	}
+
+	p.sweep(now)
+
+	return ok, retryAfter, nil
+}
+
+// sweep deletes the buckets that have been full for a period, once a period.
+func (p *Postgres) sweep(now time.Time) {
+	p.mu.Lock()
+	if now.Sub(p.lastSweep) < p.period {
+		p.mu.Unlock()
+		return
+	}
+	p.lastSweep = now
+	p.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A bucket untouched for a period is full whatever it held.
+	p.db.ExecContext(ctx, "DELETE FROM rate_limits WHERE key LIKE $1 AND updated_at < $2",
+		p.name+" %", now.Add(-p.period))
+}
@@ -0,0 +1,162 @@
+// Package ratelimit limits how often a key, such as a user or a client IP,
+// may do something, with token buckets kept in memory or in Postgres.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store takes tokens from the buckets of keys. If there is none, it returns
+// false and the time until the next one.
+type Store interface {
+	Take(key string) (ok bool, retryAfter time.Duration, err error)
+}
+
+// rate is a token bucket that holds limit tokens and gets them back evenly
+// over period.
+type rate struct {
+	limit  int
+	period time.Duration
+}
+
+// Limiter is a token bucket per key: a key may be used limit times at once
+// and gets the uses back evenly over period.
+type Limiter struct {
+	rate
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func New(limit int, period time.Duration) *Limiter {
+	return &Limiter{
+		rate:    rate{limit: limit, period: period},
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// ParseRate parses a rate such as "100/1m": 100 uses at once, given back
+// over a minute. An empty rate or "off" is no limit and returns a zero
+// limit.
+func ParseRate(value string) (limit int, period time.Duration, err error) {
+	if value == "" || value == "off" {
+		return 0, 0, nil
+	}
+
+	limitValue, periodValue, found := strings.Cut(value, "/")
+	if !found {
+		return 0, 0, fmt.Errorf("rate %q must look like 100/1m", value)
+	}
+
+	limit, err = strconv.Atoi(limitValue)
+	if err != nil || limit < 1 {
+		return 0, 0, fmt.Errorf("rate %q must allow at least 1 use", value)
+	}
+
+	period, err = time.ParseDuration(periodValue)
+	if err != nil || period/time.Duration(limit) <= 0 {
+		return 0, 0, fmt.Errorf("rate %q must have a positive period", value)
+	}
+
+	return limit, period, nil
+}
+
+// Allow takes a token of key. If there is none, it returns false and the
+// time until the next one. A nil Limiter allows everything.
+func (l *Limiter) Allow(key string) (ok bool, retryAfter time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &bucket{tokens: float64(l.limit), last: now}
+		l.buckets[key] = b
+	}
+
+	return l.take(b, now)
+}
+
+// Refund gives back a token Allow took, for uses that turned out not to
+// count, such as a right password. Taking first and refunding after keeps
+// concurrent uses from getting past an empty bucket.
+func (l *Limiter) Refund(key string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b, found := l.buckets[key]
+	if !found {
+		return
+	}
+
+	now := l.now()
+	b.tokens = math.Min(l.refill(b, now)+1, float64(l.limit))
+	b.last = now
+}
+
+// Take is Allow for Limiter to be a Store.
+func (l *Limiter) Take(key string) (bool, time.Duration, error) {
+	ok, retryAfter := l.Allow(key)
+	return ok, retryAfter, nil
+}
+
+// interval is the time it takes to get one token back.
+func (r rate) interval() time.Duration {
+	return r.period / time.Duration(r.limit)
+}
+
+func (r rate) refill(b *bucket, now time.Time) float64 {
+	tokens := b.tokens + float64(now.Sub(b.last))/float64(r.interval())
+	return math.Min(tokens, float64(r.limit))
+}
+
+// take refills b up to now and takes a token from it.
+func (r rate) take(b *bucket, now time.Time) (bool, time.Duration) {
+	b.tokens = r.refill(b, now)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration(math.Ceil((1 - b.tokens) * float64(r.interval())))
+	}
+
+	b.tokens -= 1
+	return true, 0
+}
+
+// sweep forgets full buckets once a period, so keys seen once do not pile
+// up.
+func (l *Limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.period {
+		return
+	}
+	l.lastSweep = now
+
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.limit) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAllow(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	limiter := New(3, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i += 1 {
+		ok, _ := limiter.Allow("a")
+		assert.True(t, ok)
+	}
+
+	ok, retryAfter := limiter.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 20*time.Second, retryAfter)
+
+	ok, _ = limiter.Allow("b")
+	assert.True(t, ok, "keys have their own buckets")
+
+	now = now.Add(20 * time.Second)
+	ok, _ = limiter.Allow("a")
+	assert.True(t, ok)
+	ok, _ = limiter.Allow("a")
+	assert.False(t, ok)
+}
+
+func TestRefund(t *testing.T) {
+	now := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	limiter := New(2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i += 1 {
+		ok, _ := limiter.Allow("a")
+		assert.True(t, ok)
+		limiter.Refund("a")
+	}
+
+	limiter.Allow("a")
+	limiter.Allow("a")
+	ok, _ := limiter.Allow("a")
+	assert.False(t, ok)
+
+	limiter.Refund("a")
+	limiter.Refund("a")
+	limiter.Refund("a")
+	for i := 0; i < 2; i += 1 {
+		ok, _ := limiter.Allow("a")
+		assert.True(t, ok)
+	}
+	ok, _ = limiter.Allow("a")
+	assert.False(t, ok, "refunds do not go beyond the limit")
+}
+
+func TestNilLimiter(t *testing.T) {
+	var limiter *Limiter
+
+	ok, _ := limiter.Allow("a")
+	assert.True(t, ok)
+	limiter.Refund("a")
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		value   string
+		limit   int
+		period  time.Duration
+		wantErr bool
+	}{
+		{value: "100/1m", limit: 100, period: time.Minute},
+		{value: "5/15m", limit: 5, period: 15 * time.Minute},
+		{value: "", limit: 0, period: 0},
+		{value: "off", limit: 0, period: 0},
+		{value: "100", wantErr: true},
+		{value: "0/1m", wantErr: true},
+		{value: "many/1m", wantErr: true},
+		{value: "100/minute", wantErr: true},
+		{value: "100/-1m", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			limit, period, err := ParseRate(tt.value)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.limit, limit)
+			assert.Equal(t, tt.period, period)
+		})
+	}
+}
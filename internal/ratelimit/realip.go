@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RealIP makes the client IP a trusted proxy reports in header the remote
+// address of requests, for clicks.ClientIP and everything keyed by it. Of a
+// comma-separated list, such as X-Forwarded-For, the last address is taken:
+// it was added by the proxy, the ones before come from the client and may be
+// forged. Requests without a valid address keep theirs. An empty header
+// trusts no proxy. Only set it when every request comes through the proxy,
+// or clients can send the header themselves.
+func RealIP(header string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if header == "" {
+			return next
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			values := r.Header.Values(header)
+
+			if len(values) > 0 {
+				addresses := strings.Split(values[len(values)-1], ",")
+				address := strings.TrimSpace(addresses[len(addresses)-1])
+
+				if ip := net.ParseIP(address); ip != nil {
+					r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
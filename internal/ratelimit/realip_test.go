@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/VadimFilimonov/urlshortener/internal/clicks"
+)
+
+func TestRealIP(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		values []string
+		want   string
+	}{
+		{
+			name:   "No trusted header",
+			header: "",
+			values: []string{"203.0.113.7"},
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "Single address",
+			header: "X-Real-IP",
+			values: []string{"203.0.113.7"},
+			want:   "203.0.113.7",
+		},
+		{
+			name:   "Forged addresses before the one of the proxy",
+			header: "X-Forwarded-For",
+			values: []string{"198.51.100.1", "198.51.100.2, 203.0.113.7"},
+			want:   "203.0.113.7",
+		},
+		{
+			name:   "IPv6",
+			header: "X-Forwarded-For",
+			values: []string{"2001:db8::1"},
+			want:   "2001:db8::1",
+		},
+		{
+			name:   "Not an address",
+			header: "X-Forwarded-For",
+			values: []string{"unknown"},
+			want:   "10.0.0.1",
+		},
+		{
+			name:   "Missing header",
+			header: "X-Forwarded-For",
+			want:   "10.0.0.1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/", nil)
+			request.RemoteAddr = "10.0.0.1:1234"
+			for _, value := range tt.values {
+				request.Header.Add("X-Forwarded-For", value)
+				request.Header.Add("X-Real-IP", value)
+			}
+
+			var got string
+			RealIP(tt.header)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = clicks.ClientIP(r)
+			})).ServeHTTP(httptest.NewRecorder(), request)
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
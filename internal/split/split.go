@@ -0,0 +1,133 @@
+package split
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Variant is one of the destinations a link splits traffic across. It gets
+// Weight out of the total weight of the link's variants.
+type Variant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+}
+
+type Variants []Variant
+
+// Limits of variants. Weights are capped so that their total fits an int
+// whatever the number of variants. Names are stored with clicks in a
+// varchar(64) column and kept in a cookie for sticky variants, so they are
+// short and made of cookie-safe characters.
+const (
+	MaxVariants   = 100
+	MaxWeight     = 10000
+	MaxNameLength = 64
+)
+
+// Validate checks that there are at most MaxVariants variants and that
+// every one has a unique name of letters, digits, "-", "_" and ".", a URL
+// and a weight from 1 to MaxWeight.
+func (variants Variants) Validate() error {
+	if len(variants) > MaxVariants {
+		return fmt.Errorf("a link can have at most %d variants", MaxVariants)
+	}
+
+	names := make(map[string]bool, len(variants))
+
+	for _, variant := range variants {
+		if variant.Name == "" {
+			return errors.New("variant has no name")
+		}
+
+		if len(variant.Name) > MaxNameLength {
+			return fmt.Errorf("variant name %q is longer than %d characters", variant.Name, MaxNameLength)
+		}
+
+		if !validName(variant.Name) {
+			return fmt.Errorf("variant name %q may only contain letters, digits, \"-\", \"_\" and \".\"", variant.Name)
+		}
+
+		if names[variant.Name] {
+			return fmt.Errorf("variant %q is listed twice", variant.Name)
+		}
+		names[variant.Name] = true
+
+		if variant.URL == "" {
+			return fmt.Errorf("variant %q has no url", variant.Name)
+		}
+
+		if variant.Weight <= 0 || variant.Weight > MaxWeight {
+			return fmt.Errorf("variant %q must have a weight from 1 to %d", variant.Name, MaxWeight)
+		}
+	}
+
+	return nil
+}
+
+func validName(name string) bool {
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Find returns the variant called name.
+func (variants Variants) Find(name string) (Variant, bool) {
+	for _, variant := range variants {
+		if variant.Name == name {
+			return variant, true
+		}
+	}
+
+	return Variant{}, false
+}
+
+// Pick chooses a variant with a probability proportional to its weight.
+// random returns a number in [0, n), it is rand.Intn outside of tests.
+// Variants must not be empty.
+func (variants Variants) Pick(random func(n int) int) Variant {
+	total := 0
+	for _, variant := range variants {
+		total += variant.Weight
+	}
+
+	point := random(total)
+
+	for _, variant := range variants {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+
+	return variants[len(variants)-1]
+}
+
+// Value stores variants as JSON, no variants as NULL.
+func (variants Variants) Value() (driver.Value, error) {
+	if len(variants) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(variants)
+}
+
+func (variants *Variants) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*variants = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, variants)
+	case string:
+		return json.Unmarshal([]byte(value), variants)
+	default:
+		return fmt.Errorf("cannot scan %T into variants", src)
+	}
+}
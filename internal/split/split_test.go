@@ -0,0 +1,105 @@
+package split
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPick(t *testing.T) {
+	variants := Variants{
+		{Name: "a", URL: "https://example.com/a", Weight: 1},
+		{Name: "b", URL: "https://example.com/b", Weight: 3},
+	}
+
+	tests := []struct {
+		point int
+		want  string
+	}{
+		{point: 0, want: "a"},
+		{point: 1, want: "b"},
+		{point: 3, want: "b"},
+	}
+
+	for _, tt := range tests {
+		got := variants.Pick(func(n int) int {
+			assert.Equal(t, 4, n)
+			return tt.point
+		})
+		assert.Equal(t, tt.want, got.Name)
+	}
+}
+
+func manyVariants(n int) Variants {
+	variants := make(Variants, n)
+	for i := range variants {
+		variants[i] = Variant{Name: fmt.Sprintf("v%d", i), URL: "https://example.com", Weight: 1}
+	}
+	return variants
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants Variants
+		wantErr  bool
+	}{
+		{
+			name:     "Valid",
+			variants: Variants{{Name: "a", URL: "https://example.com/a", Weight: 1}, {Name: "b", URL: "https://example.com/b", Weight: 2}},
+		},
+		{
+			name:     "Duplicate name",
+			variants: Variants{{Name: "a", URL: "https://example.com/a", Weight: 1}, {Name: "a", URL: "https://example.com/b", Weight: 2}},
+			wantErr:  true,
+		},
+		{
+			name:     "Zero weight",
+			variants: Variants{{Name: "a", URL: "https://example.com/a"}},
+			wantErr:  true,
+		},
+		{
+			name:     "Weights overflowing the total",
+			variants: Variants{{Name: "a", URL: "https://example.com/a", Weight: math.MaxInt}, {Name: "b", URL: "https://example.com/b", Weight: math.MaxInt}},
+			wantErr:  true,
+		},
+		{
+			name:     "Largest weight",
+			variants: Variants{{Name: "a", URL: "https://example.com/a", Weight: MaxWeight}},
+		},
+		{
+			name:     "Too many variants",
+			variants: manyVariants(MaxVariants + 1),
+			wantErr:  true,
+		},
+		{
+			name:     "Long name",
+			variants: Variants{{Name: strings.Repeat("a", MaxNameLength+1), URL: "https://example.com/a", Weight: 1}},
+			wantErr:  true,
+		},
+		{
+			name:     "Name that is not cookie-safe",
+			variants: Variants{{Name: "a; b", URL: "https://example.com/a", Weight: 1}},
+			wantErr:  true,
+		},
+		{
+			name:     "No url",
+			variants: Variants{{Name: "a", Weight: 1}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.variants.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
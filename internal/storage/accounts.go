@@ -0,0 +1,20 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// Account is a registered user. Its ID is the user ID of the links it owns.
+type Account struct {
+	ID    string
+	Email string
+	// PasswordHash is an argon2id hash of the password.
+	PasswordHash string
+	CreatedAt    time.Time
+}
+
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrEmailTaken      = errors.New("email is already registered")
+)
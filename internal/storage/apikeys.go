@@ -0,0 +1,23 @@
+package storage
+
+import (
+	"errors"
+	"time"
+)
+
+// APIKey lets a machine client act for the user. Only the SHA-256 hash of
+// the key is stored, Prefix keeps its first characters so the user can tell
+// keys apart.
+type APIKey struct {
+	ID        string
+	UserID    string
+	Name      string
+	Prefix    string
+	Hash      string
+	Scopes    []string
+	CreatedAt time.Time
+	// RevokedAt is nil for keys that are still valid.
+	RevokedAt *time.Time
+}
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
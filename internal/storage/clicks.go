@@ -0,0 +1,191 @@
+package storage
+
+import (
+	"sort"
+	"time"
+
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
+	"github.com/VadimFilimonov/urlshortener/internal/useragent"
+)
+
+// Click is a single successful redirect. IP is anonymized, the User-Agent
+// classified and the location looked up before it gets here. Variant is set
+// for links splitting traffic.
+type Click struct {
+	ShortenURL     string    `json:"short_url"`
+	Time           time.Time `json:"time"`
+	Referer        string    `json:"referer,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	IP             string    `json:"ip,omitempty"`
+	AcceptLanguage string    `json:"accept_language,omitempty"`
+	Variant        string    `json:"variant,omitempty"`
+	useragent.Info
+	geoip.Location
+}
+
+// inRange reports whether the click happened in [from, to). Zero bounds are
+// open.
+func (c Click) inRange(from, to time.Time) bool {
+	return (from.IsZero() || !c.Time.Before(from)) && (to.IsZero() || c.Time.Before(to))
+}
+
+// ClickQuery selects click events of a link in [From, To), zero bounds are
+// open. Events come in the order they were recorded, at most Limit of them
+// when it is positive, starting after Cursor.
+type ClickQuery struct {
+	From   time.Time
+	To     time.Time
+	Cursor int64
+	Limit  int
+}
+
+// ClickPage is a page of click events. Next is the cursor of the following
+// page, zero on the last one.
+type ClickPage struct {
+	Clicks []Click
+	Next   int64
+}
+
+// collect adds the click recorded at position to the page if the query
+// selects it. It reports false once the page is full.
+func (p *ClickPage) collect(query ClickQuery, position int64, click Click) bool {
+	if position <= query.Cursor || !click.inRange(query.From, query.To) {
+		return true
+	}
+
+	if query.Limit > 0 && len(p.Clicks) == query.Limit {
+		p.Next = position - 1
+		return false
+	}
+
+	p.Clicks = append(p.Clicks, click)
+
+	return true
+}
+
+type ClickCounts struct {
+	TotalClicks int           `json:"total_clicks"`
+	LastClick   *time.Time    `json:"last_click,omitempty"`
+	Daily       []DailyClicks `json:"daily"`
+}
+
+type DailyClicks struct {
+	Date   string `json:"date"`
+	Clicks int    `json:"clicks"`
+}
+
+// Stats of a link. Bot clicks are left out of the top level counts and the
+// breakdowns and are reported in Bots instead. Variants is only filled for
+// links splitting traffic.
+type Stats struct {
+	ClickCounts
+	Devices   map[string]int `json:"devices"`
+	OS        map[string]int `json:"os"`
+	Browsers  map[string]int `json:"browsers"`
+	Countries map[string]int `json:"countries"`
+	Variants  map[string]int `json:"variants"`
+	Bots      ClickCounts    `json:"bots"`
+}
+
+// WithBots returns stats whose top level counts include bot clicks.
+func (s Stats) WithBots() Stats {
+	counter := newClickCounter()
+
+	for _, counts := range []ClickCounts{s.ClickCounts, s.Bots} {
+		for _, day := range counts.Daily {
+			counter.daily[day.Date] += day.Clicks
+		}
+
+		if counts.LastClick != nil && counts.LastClick.After(counter.lastClick) {
+			counter.lastClick = *counts.LastClick
+		}
+	}
+
+	s.ClickCounts = counter.counts()
+	return s
+}
+
+// addBreakdown counts clicks alike to click made by a human visitor. Clicks
+// from an unknown location are counted under an empty country.
+func (s *Stats) addBreakdown(click Click, clicks int) {
+	if click.Bot {
+		return
+	}
+
+	s.Devices[click.Device] += clicks
+	s.OS[click.OS] += clicks
+	s.Browsers[click.Browser] += clicks
+	s.Countries[click.Country] += clicks
+
+	if click.Variant != "" {
+		s.Variants[click.Variant] += clicks
+	}
+}
+
+const dateLayout = "2006-01-02"
+
+type clickKey struct {
+	shortenURL string
+	bot        bool
+}
+
+// clickCounter aggregates clicks of one link by UTC day for the storages
+// which count in process.
+type clickCounter struct {
+	daily     map[string]int
+	lastClick time.Time
+}
+
+func newClickCounter() *clickCounter {
+	return &clickCounter{daily: map[string]int{}}
+}
+
+func (c *clickCounter) add(date string, clicks int, lastClick time.Time) {
+	c.daily[date] += clicks
+
+	if lastClick.After(c.lastClick) {
+		c.lastClick = lastClick
+	}
+}
+
+func (c *clickCounter) counts() ClickCounts {
+	counts := ClickCounts{Daily: make([]DailyClicks, 0, len(c.daily))}
+
+	for date, clicks := range c.daily {
+		counts.TotalClicks += clicks
+		counts.Daily = append(counts.Daily, DailyClicks{Date: date, Clicks: clicks})
+	}
+
+	sort.Slice(counts.Daily, func(i, j int) bool {
+		return counts.Daily[i].Date < counts.Daily[j].Date
+	})
+
+	if !c.lastClick.IsZero() {
+		lastClick := c.lastClick
+		counts.LastClick = &lastClick
+	}
+
+	return counts
+}
+
+// newStats builds stats from the counters of human and bot clicks, either
+// may be nil. Breakdowns are left empty for the caller to fill.
+func newStats(humans, bots *clickCounter) Stats {
+	if humans == nil {
+		humans = newClickCounter()
+	}
+
+	if bots == nil {
+		bots = newClickCounter()
+	}
+
+	return Stats{
+		ClickCounts: humans.counts(),
+		Devices:     map[string]int{},
+		OS:          map[string]int{},
+		Browsers:    map[string]int{},
+		Countries:   map[string]int{},
+		Variants:    map[string]int{},
+		Bots:        bots.counts(),
+	}
+}
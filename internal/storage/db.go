@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"time"
 
 	"github.com/golang-migrate/migrate/v4"
@@ -15,7 +16,8 @@ import (
 )
 
 type dataDB struct {
-	db *sql.DB
+	db     *sql.DB
+	dedupe DedupePolicy
 }
 
 func runMigrations(db *sql.DB) error {
@@ -34,7 +36,11 @@ func runMigrations(db *sql.DB) error {
 		return err
 	}
 
-	m.Up()
+	err = m.Up()
+	if err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return err
+	}
+
 	return nil
 }
 
@@ -55,27 +61,55 @@ func InitDB(databaseDNS string) (*sql.DB, error) {
 	return db, nil
 }
 
-func NewDB(db *sql.DB) dataDB {
-	return dataDB{db: db}
+func NewDB(db *sql.DB, dedupe DedupePolicy) dataDB {
+	return dataDB{db: db, dedupe: dedupe}
 }
 
-func (data dataDB) Get(shortenURL string) (string, error) {
+const itemColumns = "user_id, shorten_url, original_url, status, created_at, redirect_type, pass_query, pass_path, utm_source, utm_medium, utm_campaign, rules, variants, sticky_variants, password_hash, one_time, activates_at, fallback_url, title, force_preview, tags, folder"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanItem(row rowScanner) (item, error) {
+	var item item
+	var createdAt sql.NullTime
+	err := row.Scan(&item.userID, &item.ShortenURL, &item.OriginalURL, &item.status, &createdAt,
+		&item.RedirectType, &item.PassQuery, &item.PassPath, &item.UTM.Source, &item.UTM.Medium, &item.UTM.Campaign,
+		&item.Rules, &item.Variants, &item.StickyVariants, &item.PasswordHash, &item.OneTime,
+		&item.ActivatesAt, &item.FallbackURL, &item.Title, &item.ForcePreview, pq.Array(&item.Tags), &item.Folder)
+	item.CreatedAt = createdAt.Time
+
+	if len(item.Tags) == 0 {
+		item.Tags = nil
+	}
+
+	return item, err
+}
+
+func (data dataDB) Get(shortenURL string) (item, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	var originalURL string
-	var status string
-	err := data.db.QueryRowContext(ctx, "SELECT original_url, status FROM urls WHERE shorten_url = $1 LIMIT 1", shortenURL).Scan(&originalURL, &status)
+	existingItem, err := scanItem(data.db.QueryRowContext(ctx, "SELECT "+itemColumns+" FROM urls WHERE shorten_url = $1 LIMIT 1", shortenURL))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return item{}, ErrURLNotFound
+	}
 
 	if err != nil {
-		return "", err
+		return item{}, err
 	}
 
-	if status == itemStatusDeleted {
-		return "", ErrURLHasBeenDeleted
+	if existingItem.status == itemStatusDeleted {
+		return item{}, ErrURLHasBeenDeleted
 	}
 
-	return originalURL, nil
+	if existingItem.status == itemStatusConsumed {
+		return item{}, ErrURLHasBeenConsumed
+	}
+
+	return existingItem, nil
 }
 
 func (data dataDB) GetItemsOfUser(userID string) ([]item, error) {
@@ -84,7 +118,7 @@ func (data dataDB) GetItemsOfUser(userID string) ([]item, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	rows, err := data.db.QueryContext(ctx, "SELECT * FROM urls WHERE user_id = $1", userID)
+	rows, err := data.db.QueryContext(ctx, "SELECT "+itemColumns+" FROM urls WHERE user_id = $1", userID)
 
 	if err != nil {
 		return items, err
@@ -92,9 +126,7 @@ func (data dataDB) GetItemsOfUser(userID string) ([]item, error) {
 	defer rows.Close()
 
 	for rows.Next() {
-		var id int
-		var item item
-		err = rows.Scan(&id, &item.userID, &item.ShortenURL, &item.OriginalURL, &item.status)
+		item, err := scanItem(rows)
 
 		if err != nil {
 			return nil, err
@@ -110,51 +142,95 @@ func (data dataDB) GetItemsOfUser(userID string) ([]item, error) {
 	return items, nil
 }
 
-func (data dataDB) Add(originalURL, userID string) (string, error) {
-	tx, err := data.db.Begin()
+func (data dataDB) Add(originalURL, userID string, options Options) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := data.db.BeginTx(ctx, nil)
 	if err != nil {
 		return "", err
 	}
 	defer tx.Rollback()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	dedupe := data.dedupe.dedupeFor(options)
 
-	stmt, err := tx.PrepareContext(ctx, "INSERT INTO urls(user_id, shorten_url, original_url, status) VALUES($1,$2,$3,$4) ON CONFLICT (original_url) DO NOTHING")
+	if dedupe != DedupeNone {
+		shortenURLPath, err := findDuplicate(ctx, tx, dedupe, originalURL, userID)
+
+		if err == nil {
+			return shortenURLPath, constants.ErrURLAlreadyExists
+		}
+
+		if !errors.Is(err, sql.ErrNoRows) {
+			return "", err
+		}
+	}
+
+	shortenURLPath, err := utils.GenerateID()
 	if err != nil {
 		return "", err
 	}
-	defer stmt.Close()
-
-	shortenURLPath := utils.GenerateID()
-	sqlResult, err := stmt.ExecContext(ctx, userID, shortenURLPath, originalURL, itemStatusCreated)
+	_, err = tx.ExecContext(ctx, "INSERT INTO urls("+itemColumns+", search) VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22,to_tsvector('simple', $23))",
+		userID, shortenURLPath, originalURL, itemStatusCreated, time.Now().UTC(), options.RedirectType, options.PassQuery, options.PassPath,
+		options.UTM.Source, options.UTM.Medium, options.UTM.Campaign, options.Rules,
+		options.Variants, options.StickyVariants, options.PasswordHash, options.OneTime,
+		options.ActivatesAt, options.FallbackURL, options.Title, options.ForcePreview,
+		pq.Array(options.Tags), options.Folder, searchText(originalURL, options),
+	)
 	if err != nil {
 		return "", err
 	}
 
-	rowsAffected, err := sqlResult.RowsAffected()
+	err = tx.Commit()
+
 	if err != nil {
 		return "", err
 	}
 
-	err = tx.Commit()
+	return shortenURLPath, nil
+}
 
+// findDuplicate looks up a short URL which Add must reuse according to the
+// dedupe policy, sql.ErrNoRows if there is none. The advisory lock
+// serializes concurrent Add calls for the same originalURL until tx ends, so
+// two of them cannot both miss.
+func findDuplicate(ctx context.Context, tx *sql.Tx, dedupe DedupePolicy, originalURL, userID string) (string, error) {
+	_, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", originalURL)
 	if err != nil {
 		return "", err
 	}
 
-	hasURLBeenAdded := rowsAffected != 0
-	if !hasURLBeenAdded {
-		err = data.db.QueryRowContext(ctx, "SELECT shorten_url FROM urls WHERE original_url = $1 LIMIT 1", originalURL).Scan(&shortenURLPath)
+	query := "SELECT " + itemColumns + " FROM urls WHERE original_url = $1 AND status = $2"
+	args := []any{originalURL, itemStatusCreated}
 
+	if dedupe == DedupeUser {
+		query += " AND user_id = $3"
+		args = append(args, userID)
+	}
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		item, err := scanItem(rows)
 		if err != nil {
 			return "", err
 		}
 
-		return shortenURLPath, constants.ErrURLAlreadyExists
+		if dedupe.isDuplicate(item, originalURL, userID) {
+			return item.ShortenURL, nil
+		}
 	}
 
-	return shortenURLPath, nil
+	err = rows.Err()
+	if err != nil {
+		return "", err
+	}
+
+	return "", sql.ErrNoRows
 }
 
 func (data dataDB) Delete(ids []string, userID string) error {
@@ -165,3 +241,31 @@ func (data dataDB) Delete(ids []string, userID string) error {
 	_, err := data.db.ExecContext(ctx, query, itemStatusDeleted, userID, pq.Array(ids))
 	return err
 }
+
+// Consume relies on the row lock taken by UPDATE: a concurrent call waits
+// for the first one to commit and then no longer matches the created status.
+func (data dataDB) Consume(shortenURL string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := data.db.ExecContext(ctx, "UPDATE urls SET status = $1 WHERE shorten_url = $2 AND status = $3", itemStatusConsumed, shortenURL, itemStatusCreated)
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 1 {
+		return nil
+	}
+
+	_, err = data.Get(shortenURL)
+	if err != nil {
+		return err
+	}
+
+	return ErrURLHasBeenConsumed
+}
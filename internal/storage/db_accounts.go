@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func (data dataDB) AddAccount(account Account) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := data.db.ExecContext(ctx, "INSERT INTO accounts(id, email, password_hash, created_at) VALUES($1,$2,$3,$4)",
+		account.ID, account.Email, account.PasswordHash, account.CreatedAt)
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrEmailTaken
+	}
+
+	return err
+}
+
+func (data dataDB) getAccount(column, value string) (Account, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var account Account
+	err := data.db.QueryRowContext(ctx, "SELECT id, email, password_hash, created_at FROM accounts WHERE "+column+" = $1", value).
+		Scan(&account.ID, &account.Email, &account.PasswordHash, &account.CreatedAt)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return Account{}, ErrAccountNotFound
+	}
+
+	return account, err
+}
+
+func (data dataDB) GetAccount(id string) (Account, error) {
+	return data.getAccount("id", id)
+}
+
+func (data dataDB) GetAccountByEmail(email string) (Account, error) {
+	return data.getAccount("email", email)
+}
+
+func (data dataDB) ClaimItems(fromUserID, toUserID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := data.db.ExecContext(ctx, "UPDATE urls SET user_id = $2 WHERE user_id = $1", fromUserID, toUserID)
+	if err != nil {
+		return 0, err
+	}
+
+	claimed, err := result.RowsAffected()
+
+	return int(claimed), err
+}
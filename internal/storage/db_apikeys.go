@@ -0,0 +1,86 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const apiKeyColumns = "id, user_id, name, prefix, hash, scopes, created_at, revoked_at"
+
+func scanAPIKey(row rowScanner) (APIKey, error) {
+	var key APIKey
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &key.Prefix, &key.Hash, pq.Array(&key.Scopes), &key.CreatedAt, &key.RevokedAt)
+
+	return key, err
+}
+
+func (data dataDB) AddAPIKey(key APIKey) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := data.db.ExecContext(ctx, "INSERT INTO api_keys("+apiKeyColumns+") VALUES($1,$2,$3,$4,$5,$6,$7,$8)",
+		key.ID, key.UserID, key.Name, key.Prefix, key.Hash, pq.Array(key.Scopes), key.CreatedAt, key.RevokedAt)
+
+	return err
+}
+
+func (data dataDB) GetAPIKeys(userID string) ([]APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	rows, err := data.db.QueryContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = $1 ORDER BY created_at", userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+func (data dataDB) GetAPIKeyByHash(hash string) (APIKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	key, err := scanAPIKey(data.db.QueryRowContext(ctx, "SELECT "+apiKeyColumns+" FROM api_keys WHERE hash = $1", hash))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	return key, err
+}
+
+func (data dataDB) RevokeAPIKey(id, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := data.db.ExecContext(ctx, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, $3) WHERE id = $1 AND user_id = $2", id, userID, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if updated == 0 {
+		return ErrAPIKeyNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,236 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const clickColumns = "shorten_url, clicked_at, referer, user_agent, ip, accept_language, device, os, browser, bot, country, region, variant"
+
+// scanClick scans a row of clickColumns, preceded by the columns scanned
+// into leading.
+func scanClick(row rowScanner, leading ...any) (Click, error) {
+	var click Click
+	err := row.Scan(append(leading, &click.ShortenURL, &click.Time, &click.Referer, &click.UserAgent, &click.IP, &click.AcceptLanguage,
+		&click.Device, &click.OS, &click.Browser, &click.Bot, &click.Country, &click.Region, &click.Variant)...)
+
+	return click, err
+}
+
+func (data dataDB) AddClicks(clicks []Click) error {
+	type dailyKey struct {
+		clickKey
+		date string
+	}
+	type dailyClicks struct {
+		clicks    int
+		lastClick time.Time
+	}
+
+	daily := map[dailyKey]*dailyClicks{}
+
+	for _, click := range clicks {
+		key := dailyKey{
+			clickKey: clickKey{shortenURL: click.ShortenURL, bot: click.Bot},
+			date:     click.Time.UTC().Format(dateLayout),
+		}
+
+		counter, ok := daily[key]
+		if !ok {
+			counter = &dailyClicks{}
+			daily[key] = counter
+		}
+
+		counter.clicks += 1
+		if click.Time.After(counter.lastClick) {
+			counter.lastClick = click.Time
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := data.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO url_clicks(shorten_url, day, bot, clicks, last_click) VALUES($1,$2,$3,$4,$5)
+		ON CONFLICT (shorten_url, day, bot) DO UPDATE SET
+			clicks = url_clicks.clicks + EXCLUDED.clicks,
+			last_click = GREATEST(url_clicks.last_click, EXCLUDED.last_click)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for key, counter := range daily {
+		_, err = stmt.ExecContext(ctx, key.shortenURL, key.date, key.bot, counter.clicks, counter.lastClick)
+		if err != nil {
+			return err
+		}
+	}
+
+	eventStmt, err := tx.PrepareContext(ctx, "INSERT INTO clicks("+clickColumns+") VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13)")
+	if err != nil {
+		return err
+	}
+	defer eventStmt.Close()
+
+	for _, click := range clicks {
+		_, err = eventStmt.ExecContext(ctx, click.ShortenURL, click.Time, click.Referer, click.UserAgent, click.IP, click.AcceptLanguage,
+			click.Device, click.OS, click.Browser, click.Bot, click.Country, click.Region, click.Variant)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// checkOwner returns ErrURLNotFound unless userID owns shortenURL.
+func (data dataDB) checkOwner(ctx context.Context, shortenURL, userID string) error {
+	var exists int
+	err := data.db.QueryRowContext(ctx, "SELECT 1 FROM urls WHERE shorten_url = $1 AND user_id = $2 LIMIT 1", shortenURL, userID).Scan(&exists)
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrURLNotFound
+	}
+
+	return err
+}
+
+func (data dataDB) GetStats(shortenURL, userID string) (Stats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := data.checkOwner(ctx, shortenURL, userID)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	rows, err := data.db.QueryContext(ctx, "SELECT day, bot, clicks, last_click FROM url_clicks WHERE shorten_url = $1", shortenURL)
+	if err != nil {
+		return Stats{}, err
+	}
+	defer rows.Close()
+
+	humans := newClickCounter()
+	bots := newClickCounter()
+
+	for rows.Next() {
+		var day time.Time
+		var bot bool
+		var clicks int
+		var lastClick time.Time
+
+		err = rows.Scan(&day, &bot, &clicks, &lastClick)
+		if err != nil {
+			return Stats{}, err
+		}
+
+		if bot {
+			bots.add(day.Format(dateLayout), clicks, lastClick)
+		} else {
+			humans.add(day.Format(dateLayout), clicks, lastClick)
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := newStats(humans, bots)
+
+	err = data.addBreakdown(ctx, shortenURL, &stats)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+func (data dataDB) addBreakdown(ctx context.Context, shortenURL string, stats *Stats) error {
+	rows, err := data.db.QueryContext(ctx, "SELECT device, os, browser, country, variant, count(*) FROM clicks WHERE shorten_url = $1 AND NOT bot GROUP BY device, os, browser, country, variant", shortenURL)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var click Click
+		var clicks int
+
+		err = rows.Scan(&click.Device, &click.OS, &click.Browser, &click.Country, &click.Variant, &clicks)
+		if err != nil {
+			return err
+		}
+		stats.addBreakdown(click, clicks)
+	}
+
+	return rows.Err()
+}
+
+func (data dataDB) GetClicks(shortenURL, userID string, query ClickQuery) (ClickPage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := data.checkOwner(ctx, shortenURL, userID)
+	if err != nil {
+		return ClickPage{}, err
+	}
+
+	statement := "SELECT id, " + clickColumns + " FROM clicks WHERE shorten_url = $1 AND id > $2"
+	args := []any{shortenURL, query.Cursor}
+
+	if !query.From.IsZero() {
+		args = append(args, query.From)
+		statement += fmt.Sprintf(" AND clicked_at >= $%d", len(args))
+	}
+
+	if !query.To.IsZero() {
+		args = append(args, query.To)
+		statement += fmt.Sprintf(" AND clicked_at < $%d", len(args))
+	}
+
+	statement += " ORDER BY id"
+
+	// One more row than asked for tells whether there is a next page.
+	if query.Limit > 0 {
+		args = append(args, query.Limit+1)
+		statement += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := data.db.QueryContext(ctx, statement, args...)
+	if err != nil {
+		return ClickPage{}, err
+	}
+	defer rows.Close()
+
+	page := ClickPage{Clicks: make([]Click, 0)}
+
+	for rows.Next() {
+		var id int64
+
+		click, err := scanClick(rows, &id)
+		if err != nil {
+			return ClickPage{}, err
+		}
+
+		if !page.collect(query, id, click) {
+			break
+		}
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return ClickPage{}, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+func (data dataDB) Update(shortenURL, userID, originalURL string, options Options) error {
+	return data.Patch(shortenURL, userID, replaceWith(originalURL, options))
+}
+
+func (data dataDB) Patch(shortenURL, userID string, patch PatchFunc) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tx, err := data.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	existingItem, err := scanItem(tx.QueryRowContext(ctx, "SELECT "+itemColumns+" FROM urls WHERE shorten_url = $1 AND user_id = $2 FOR UPDATE", shortenURL, userID))
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrURLNotFound
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if existingItem.status == itemStatusDeleted {
+		return ErrURLHasBeenDeleted
+	}
+
+	if existingItem.status == itemStatusConsumed {
+		return ErrURLHasBeenConsumed
+	}
+
+	originalURL, options, err := patch(existingItem.OriginalURL, existingItem.Options)
+	if err != nil {
+		return err
+	}
+
+	replacedOptions, err := json.Marshal(existingItem.Options)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO url_revisions(shorten_url, revision, original_url, options, replaced_at)
+		SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4 FROM url_revisions WHERE shorten_url = $1`,
+		shortenURL, existingItem.OriginalURL, replacedOptions, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE urls SET original_url = $2, redirect_type = $3, pass_query = $4, pass_path = $5,
+		utm_source = $6, utm_medium = $7, utm_campaign = $8, rules = $9, variants = $10, sticky_variants = $11, password_hash = $12, one_time = $13,
+		activates_at = $14, fallback_url = $15, title = $16, force_preview = $17, tags = $18, folder = $19, search = to_tsvector('simple', $20)
+		WHERE shorten_url = $1`,
+		shortenURL, originalURL, options.RedirectType, options.PassQuery, options.PassPath,
+		options.UTM.Source, options.UTM.Medium, options.UTM.Campaign, options.Rules,
+		options.Variants, options.StickyVariants, options.PasswordHash, options.OneTime,
+		options.ActivatesAt, options.FallbackURL, options.Title, options.ForcePreview,
+		pq.Array(options.Tags), options.Folder, searchText(originalURL, options),
+	)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (data dataDB) GetRevisions(shortenURL, userID string) ([]Revision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := data.checkOwner(ctx, shortenURL, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := data.db.QueryContext(ctx, "SELECT revision, original_url, options, replaced_at FROM url_revisions WHERE shorten_url = $1 ORDER BY revision", shortenURL)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := make([]Revision, 0)
+
+	for rows.Next() {
+		var revision Revision
+		var options []byte
+
+		err = rows.Scan(&revision.Number, &revision.OriginalURL, &options, &revision.ReplacedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		err = json.Unmarshal(options, &revision.Options)
+		if err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return revisions, nil
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SearchItemsOfUser relies on the GIN indexes on tags and search and the
+// (user_id, folder) index. Every query word is matched as a prefix, like in
+// searchIndex.
+func (data dataDB) SearchItemsOfUser(userID string, filter Filter) ([]item, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	query := "SELECT " + itemColumns + " FROM urls WHERE user_id = $1"
+	args := []any{userID}
+
+	if len(filter.Tags) > 0 {
+		args = append(args, pq.Array(filter.Tags))
+		query += fmt.Sprintf(" AND tags @> $%d", len(args))
+	}
+
+	if filter.Folder != nil {
+		args = append(args, *filter.Folder)
+		query += fmt.Sprintf(" AND folder = $%d", len(args))
+	}
+
+	if words := searchWords(filter.Query); len(words) > 0 {
+		args = append(args, strings.Join(words, ":* & ")+":*")
+		query += fmt.Sprintf(" AND search @@ to_tsquery('simple', $%d)", len(args))
+	}
+
+	rows, err := data.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make([]item, 0)
+
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
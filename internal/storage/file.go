@@ -2,166 +2,451 @@ package storage
 
 import (
 	"bufio"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"golang.org/x/exp/slices"
 
+	"github.com/VadimFilimonov/urlshortener/internal/constants"
 	utils "github.com/VadimFilimonov/urlshortener/internal/utils/generateid"
 )
 
 type dataFile struct {
 	filename string
-	items    map[string]item
+	dedupe   DedupePolicy
+	mu       *sync.RWMutex
+	index    *fileIndex
 }
 
-func NewFile(filename string) dataFile {
+func NewFile(filename string, dedupe DedupePolicy) dataFile {
 	return dataFile{
 		filename: filename,
-		items:    map[string]item{},
+		dedupe:   dedupe,
+		mu:       &sync.RWMutex{},
+		index:    &fileIndex{},
 	}
 }
 
-func (d dataFile) Get(shortenURL string) (string, error) {
-	data, err := os.ReadFile(d.filename)
+// Every row of the file is "shortenURL originalURL userID status options",
+// where options is a URL-encoded query string of the link options and its
+// creation time. Rows written before options existed have no fifth column.
+func formatRow(item item) (string, error) {
+	row := fmt.Sprintf("%s %s %s %s", item.ShortenURL, item.OriginalURL, item.userID, item.status)
+	values := url.Values{}
 
+	err := encodeOptions(item.Options, values)
 	if err != nil {
 		return "", err
 	}
 
-	rows := strings.Split(string(data), "\n")
-	var originalURL string
-	var status string
+	if !item.CreatedAt.IsZero() {
+		values.Set("created_at", item.CreatedAt.Format(time.RFC3339Nano))
+	}
 
-	for _, row := range rows {
-		if strings.Contains(row, shortenURL) {
-			columns := strings.Split(row, " ")
-			originalURL = columns[1]
-			status = columns[3]
-			break
+	if len(values) > 0 {
+		row += " " + values.Encode()
+	}
+
+	return row, nil
+}
+
+func parseRow(row string) (item, error) {
+	columns := strings.Split(row, " ")
+
+	if len(columns) < 4 {
+		return item{}, fmt.Errorf("malformed row %q", row)
+	}
+
+	parsedItem := item{
+		ShortenURL:  columns[0],
+		OriginalURL: columns[1],
+		userID:      columns[2],
+		status:      columns[3],
+	}
+
+	if len(columns) > 4 {
+		values, err := url.ParseQuery(columns[4])
+		if err != nil {
+			return item{}, fmt.Errorf("malformed row %q: %w", row, err)
+		}
+
+		parsedItem.Options, err = decodeOptions(values)
+		if err != nil {
+			return item{}, fmt.Errorf("malformed row %q: %w", row, err)
+		}
+
+		if value := values.Get("created_at"); value != "" {
+			parsedItem.CreatedAt, err = time.Parse(time.RFC3339Nano, value)
+			if err != nil {
+				return item{}, fmt.Errorf("malformed row %q: %w", row, err)
+			}
+		}
+	}
+
+	return parsedItem, nil
+}
+
+func encodeOptions(options Options, values url.Values) error {
+	if options.RedirectType != 0 {
+		values.Set("redirect_type", strconv.Itoa(options.RedirectType))
+	}
+
+	if options.PassQuery {
+		values.Set("pass_query", "1")
+	}
+
+	if options.PassPath {
+		values.Set("pass_path", "1")
+	}
+
+	if options.UTM.Source != "" {
+		values.Set("utm_source", options.UTM.Source)
+	}
+
+	if options.UTM.Medium != "" {
+		values.Set("utm_medium", options.UTM.Medium)
+	}
+
+	if options.UTM.Campaign != "" {
+		values.Set("utm_campaign", options.UTM.Campaign)
+	}
+
+	if len(options.Rules) > 0 {
+		rules, err := json.Marshal(options.Rules)
+		if err != nil {
+			return err
+		}
+		values.Set("rules", string(rules))
+	}
+
+	if len(options.Variants) > 0 {
+		variants, err := json.Marshal(options.Variants)
+		if err != nil {
+			return err
+		}
+		values.Set("variants", string(variants))
+	}
+
+	if options.StickyVariants {
+		values.Set("sticky_variants", "1")
+	}
+
+	if options.PasswordHash != "" {
+		values.Set("password_hash", options.PasswordHash)
+	}
+
+	if options.OneTime {
+		values.Set("one_time", "1")
+	}
+
+	if options.ActivatesAt != nil {
+		values.Set("activates_at", options.ActivatesAt.Format(time.RFC3339Nano))
+	}
+
+	if options.FallbackURL != "" {
+		values.Set("fallback_url", options.FallbackURL)
+	}
+
+	if options.Title != "" {
+		values.Set("title", options.Title)
+	}
+
+	if options.ForcePreview {
+		values.Set("force_preview", "1")
+	}
+
+	for _, tag := range options.Tags {
+		values.Add("tag", tag)
+	}
+
+	if options.Folder != "" {
+		values.Set("folder", options.Folder)
+	}
+
+	return nil
+}
+
+func decodeOptions(values url.Values) (Options, error) {
+	var options Options
+	var err error
+
+	if value := values.Get("redirect_type"); value != "" {
+		options.RedirectType, err = strconv.Atoi(value)
+		if err != nil {
+			return options, err
+		}
+	}
+
+	options.PassQuery = values.Get("pass_query") == "1"
+	options.PassPath = values.Get("pass_path") == "1"
+	options.UTM.Source = values.Get("utm_source")
+	options.UTM.Medium = values.Get("utm_medium")
+	options.UTM.Campaign = values.Get("utm_campaign")
+
+	if value := values.Get("rules"); value != "" {
+		err = json.Unmarshal([]byte(value), &options.Rules)
+		if err != nil {
+			return options, err
 		}
 	}
 
-	if originalURL == "" {
-		return "", errors.New("incorrect shortenURL")
+	if value := values.Get("variants"); value != "" {
+		err = json.Unmarshal([]byte(value), &options.Variants)
+		if err != nil {
+			return options, err
+		}
 	}
 
-	if status == itemStatusDeleted {
-		return "", ErrURLHasBeenDeleted
+	options.StickyVariants = values.Get("sticky_variants") == "1"
+	options.PasswordHash = values.Get("password_hash")
+	options.OneTime = values.Get("one_time") == "1"
+	options.FallbackURL = values.Get("fallback_url")
+	options.Title = values.Get("title")
+	options.ForcePreview = values.Get("force_preview") == "1"
+	options.Tags = values["tag"]
+	options.Folder = values.Get("folder")
+
+	if value := values.Get("activates_at"); value != "" {
+		activatesAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return options, err
+		}
+		options.ActivatesAt = &activatesAt
 	}
 
-	return originalURL, nil
+	return options, nil
 }
 
-func (d dataFile) GetItemsOfUser(userID string) ([]item, error) {
-	items := make([]item, 0)
+func (d dataFile) readItems() ([]item, error) {
 	data, err := os.ReadFile(d.filename)
 
+	if errors.Is(err, os.ErrNotExist) {
+		return []item{}, nil
+	}
+
 	if err != nil {
 		return nil, err
 	}
 
 	rows := strings.Split(string(data), "\n")
+	items := make([]item, 0, len(rows))
 
 	for _, row := range rows {
-		if strings.Contains(row, userID) {
-			columns := strings.Split(row, " ")
-			item := item{
-				userID:      columns[2],
-				ShortenURL:  columns[0],
-				OriginalURL: columns[1],
-				status:      columns[3],
-			}
-			items = append(items, item)
+		if row == "" {
+			continue
 		}
+
+		item, err := parseRow(row)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
 	}
 
 	return items, nil
 }
 
-func (d dataFile) Add(originalURL, userID string) (string, error) {
-	file, err := os.OpenFile(d.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+func (d dataFile) writeItems(items []item) error {
+	d.index.invalidate()
+	rows := make([]string, len(items))
+
+	for index, item := range items {
+		row, err := formatRow(item)
+		if err != nil {
+			return err
+		}
+		rows[index] = row
+	}
+
+	return writeRows(d.filename, rows)
+}
+
+func writeRows(filename string, rows []string) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+
 	if err != nil {
-		return "", err
+		return err
 	}
+
 	writer := bufio.NewWriter(file)
-	shortenURLPath := utils.GenerateID()
-	data := fmt.Sprintf("%s %s %s %s\n", shortenURLPath, originalURL, userID, itemStatusCreated)
-	_, err = writer.Write([]byte(data))
 
-	if err != nil {
-		return "", err
+	for _, row := range rows {
+		_, err = writer.WriteString(row + "\n")
+
+		if err != nil {
+			file.Close()
+			return err
+		}
 	}
 
 	err = writer.Flush()
-	file.Close()
 
 	if err != nil {
-		return "", err
+		file.Close()
+		return err
 	}
 
-	return shortenURLPath, nil
+	return file.Close()
 }
 
-func (d dataFile) Delete(ids []string, userID string) error {
-	data, err := os.ReadFile(d.filename)
+func (d dataFile) Get(shortenURL string) (item, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	items, err := d.readItems()
 
 	if err != nil {
-		return err
+		return item{}, err
 	}
 
-	rows := strings.Split(string(data), "\n")
-	processedRows := make([]string, len(rows))
-
-	for index, row := range rows {
-		if row == "" {
+	for _, existingItem := range items {
+		if existingItem.ShortenURL != shortenURL {
 			continue
 		}
 
-		columns := strings.Split(row, " ")
-		shortenURL := columns[0]
-		originalURL := columns[1]
-		userIDOfOwner := columns[2]
+		if existingItem.status == itemStatusDeleted {
+			return item{}, ErrURLHasBeenDeleted
+		}
 
-		if userID != userIDOfOwner {
-			continue
+		if existingItem.status == itemStatusConsumed {
+			return item{}, ErrURLHasBeenConsumed
 		}
 
-		if slices.Contains(ids, shortenURL) {
-			processedRow := fmt.Sprintf("%s %s %s %s", shortenURL, originalURL, userIDOfOwner, itemStatusDeleted)
-			processedRows[index] = processedRow
-		} else {
-			processedRows[index] = row
+		return existingItem, nil
+	}
+
+	return item{}, ErrURLNotFound
+}
+
+func (d dataFile) GetItemsOfUser(userID string) ([]item, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	items, err := d.readItems()
+
+	if err != nil {
+		return nil, err
+	}
+
+	userItems := make([]item, 0)
+
+	for _, item := range items {
+		if item.userID == userID {
+			userItems = append(userItems, item)
 		}
 	}
-	processedData := []byte(strings.Join(processedRows, "\n"))
 
-	file, err := os.OpenFile(d.filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+	return userItems, nil
+}
+
+func (d dataFile) Add(originalURL, userID string, options Options) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
+	dedupe := d.dedupe.dedupeFor(options)
+
+	if dedupe != DedupeNone {
+		items, err := d.readItems()
+		if err != nil {
+			return "", err
+		}
+
+		for _, item := range items {
+			if dedupe.isDuplicate(item, originalURL, userID) {
+				return item.ShortenURL, constants.ErrURLAlreadyExists
+			}
+		}
+	}
+
+	shortenURLPath, err := utils.GenerateID()
 	if err != nil {
-		return err
+		return "", err
+	}
+	row, err := formatRow(item{
+		userID:      userID,
+		ShortenURL:  shortenURLPath,
+		OriginalURL: originalURL,
+		status:      itemStatusCreated,
+		CreatedAt:   time.Now().UTC(),
+		Options:     options,
+	})
+	if err != nil {
+		return "", err
 	}
 
+	d.index.invalidate()
+
+	file, err := os.OpenFile(d.filename, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	if err != nil {
+		return "", err
+	}
 	writer := bufio.NewWriter(file)
-	_, err = writer.Write(processedData)
+	_, err = writer.Write([]byte(row + "\n"))
 
 	if err != nil {
-		return err
+		file.Close()
+		return "", err
 	}
 
 	err = writer.Flush()
+	file.Close()
+
+	if err != nil {
+		return "", err
+	}
+
+	return shortenURLPath, nil
+}
+
+func (d dataFile) Delete(ids []string, userID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	items, err := d.readItems()
 
 	if err != nil {
 		return err
 	}
 
-	err = file.Close()
+	for index, item := range items {
+		if item.userID == userID && slices.Contains(ids, item.ShortenURL) {
+			items[index].status = itemStatusDeleted
+		}
+	}
+
+	return d.writeItems(items)
+}
+
+func (d dataFile) Consume(shortenURL string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
 
+	items, err := d.readItems()
 	if err != nil {
 		return err
 	}
 
-	return nil
+	index := slices.IndexFunc(items, func(item item) bool {
+		return item.ShortenURL == shortenURL
+	})
+
+	switch {
+	case index < 0:
+		return ErrURLNotFound
+	case items[index].status == itemStatusDeleted:
+		return ErrURLHasBeenDeleted
+	case items[index].status == itemStatusConsumed:
+		return ErrURLHasBeenConsumed
+	}
+
+	items[index].status = itemStatusConsumed
+
+	return d.writeItems(items)
 }
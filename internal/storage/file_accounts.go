@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// Accounts are appended to a file next to the links file, one row per
+// account: "id email passwordHash createdAt".
+func (d dataFile) accountsFilename() string {
+	return d.filename + ".accounts"
+}
+
+func (d dataFile) readAccounts() ([]Account, error) {
+	data, err := os.ReadFile(d.accountsFilename())
+
+	if errors.Is(err, os.ErrNotExist) {
+		return []Account{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	accounts := make([]Account, 0)
+
+	for _, row := range strings.Split(string(data), "\n") {
+		if row == "" {
+			continue
+		}
+
+		columns := strings.Split(row, " ")
+		if len(columns) != 4 {
+			return nil, fmt.Errorf("malformed account row %q", row)
+		}
+
+		createdAt, err := time.Parse(time.RFC3339Nano, columns[3])
+		if err != nil {
+			return nil, fmt.Errorf("malformed account row %q: %w", row, err)
+		}
+
+		accounts = append(accounts, Account{
+			ID:           columns[0],
+			Email:        columns[1],
+			PasswordHash: columns[2],
+			CreatedAt:    createdAt,
+		})
+	}
+
+	return accounts, nil
+}
+
+func (d dataFile) AddAccount(account Account) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	accounts, err := d.readAccounts()
+	if err != nil {
+		return err
+	}
+
+	for _, existing := range accounts {
+		if existing.Email == account.Email {
+			return ErrEmailTaken
+		}
+	}
+
+	file, err := os.OpenFile(d.accountsFilename(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	_, err = fmt.Fprintf(writer, "%s %s %s %s\n", account.ID, account.Email, account.PasswordHash, account.CreatedAt.Format(time.RFC3339Nano))
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = writer.Flush()
+	file.Close()
+
+	return err
+}
+
+func (d dataFile) findAccount(match func(Account) bool) (Account, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	accounts, err := d.readAccounts()
+	if err != nil {
+		return Account{}, err
+	}
+
+	for _, account := range accounts {
+		if match(account) {
+			return account, nil
+		}
+	}
+
+	return Account{}, ErrAccountNotFound
+}
+
+func (d dataFile) GetAccount(id string) (Account, error) {
+	return d.findAccount(func(account Account) bool {
+		return account.ID == id
+	})
+}
+
+func (d dataFile) GetAccountByEmail(email string) (Account, error) {
+	return d.findAccount(func(account Account) bool {
+		return account.Email == email
+	})
+}
+
+func (d dataFile) ClaimItems(fromUserID, toUserID string) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	items, err := d.readItems()
+	if err != nil {
+		return 0, err
+	}
+
+	claimed := 0
+
+	for i := range items {
+		if items[i].userID == fromUserID {
+			items[i].userID = toUserID
+			claimed++
+		}
+	}
+
+	if claimed == 0 {
+		return 0, nil
+	}
+
+	return claimed, d.writeItems(items)
+}
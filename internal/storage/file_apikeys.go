@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// API keys are kept in a file next to the links file, one row per key:
+// "id userID hash attributes", where attributes is a URL-encoded query
+// string of the rest of the key.
+func (d dataFile) apiKeysFilename() string {
+	return d.filename + ".keys"
+}
+
+func formatAPIKeyRow(key APIKey) string {
+	values := url.Values{}
+	values.Set("name", key.Name)
+	values.Set("prefix", key.Prefix)
+	values.Set("created_at", key.CreatedAt.Format(time.RFC3339Nano))
+
+	for _, scope := range key.Scopes {
+		values.Add("scope", scope)
+	}
+
+	if key.RevokedAt != nil {
+		values.Set("revoked_at", key.RevokedAt.Format(time.RFC3339Nano))
+	}
+
+	return fmt.Sprintf("%s %s %s %s", key.ID, key.UserID, key.Hash, values.Encode())
+}
+
+func parseAPIKeyRow(row string) (APIKey, error) {
+	columns := strings.Split(row, " ")
+
+	if len(columns) != 4 {
+		return APIKey{}, fmt.Errorf("malformed api key row %q", row)
+	}
+
+	values, err := url.ParseQuery(columns[3])
+	if err != nil {
+		return APIKey{}, fmt.Errorf("malformed api key row %q: %w", row, err)
+	}
+
+	key := APIKey{
+		ID:     columns[0],
+		UserID: columns[1],
+		Hash:   columns[2],
+		Name:   values.Get("name"),
+		Prefix: values.Get("prefix"),
+		Scopes: values["scope"],
+	}
+
+	key.CreatedAt, err = time.Parse(time.RFC3339Nano, values.Get("created_at"))
+	if err != nil {
+		return APIKey{}, fmt.Errorf("malformed api key row %q: %w", row, err)
+	}
+
+	if value := values.Get("revoked_at"); value != "" {
+		revokedAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return APIKey{}, fmt.Errorf("malformed api key row %q: %w", row, err)
+		}
+		key.RevokedAt = &revokedAt
+	}
+
+	return key, nil
+}
+
+func (d dataFile) readAPIKeys() ([]APIKey, error) {
+	data, err := os.ReadFile(d.apiKeysFilename())
+
+	if errors.Is(err, os.ErrNotExist) {
+		return []APIKey{}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]APIKey, 0)
+
+	for _, row := range strings.Split(string(data), "\n") {
+		if row == "" {
+			continue
+		}
+
+		key, err := parseAPIKeyRow(row)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (d dataFile) writeAPIKeys(keys []APIKey) error {
+	rows := make([]string, len(keys))
+
+	for i, key := range keys {
+		rows[i] = formatAPIKeyRow(key)
+	}
+
+	return writeRows(d.apiKeysFilename(), rows)
+}
+
+func (d dataFile) AddAPIKey(key APIKey) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys, err := d.readAPIKeys()
+	if err != nil {
+		return err
+	}
+
+	return d.writeAPIKeys(append(keys, key))
+}
+
+func (d dataFile) GetAPIKeys(userID string) ([]APIKey, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	keys, err := d.readAPIKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	userKeys := make([]APIKey, 0)
+
+	for _, key := range keys {
+		if key.UserID == userID {
+			userKeys = append(userKeys, key)
+		}
+	}
+
+	return userKeys, nil
+}
+
+func (d dataFile) GetAPIKeyByHash(hash string) (APIKey, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	keys, err := d.readAPIKeys()
+	if err != nil {
+		return APIKey{}, err
+	}
+
+	for _, key := range keys {
+		if key.Hash == hash {
+			return key, nil
+		}
+	}
+
+	return APIKey{}, ErrAPIKeyNotFound
+}
+
+func (d dataFile) RevokeAPIKey(id, userID string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys, err := d.readAPIKeys()
+	if err != nil {
+		return err
+	}
+
+	for i, key := range keys {
+		if key.ID != id || key.UserID != userID {
+			continue
+		}
+
+		if key.RevokedAt != nil {
+			return nil
+		}
+
+		revokedAt := time.Now().UTC()
+		keys[i].RevokedAt = &revokedAt
+
+		return d.writeAPIKeys(keys)
+	}
+
+	return ErrAPIKeyNotFound
+}
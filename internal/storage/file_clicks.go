@@ -0,0 +1,251 @@
+package storage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Click counters live next to the links file. Every batch of clicks appends
+// a row per link and kind of visitor, "shortenURL lastClick
+// date:clicks,date:clicks [bot]", and the rows add up.
+func (d dataFile) statsFilename() string {
+	return d.filename + ".stats"
+}
+
+// Click events are appended to a log next to the links file, one JSON
+// object per line.
+func (d dataFile) clicksFilename() string {
+	return d.filename + ".clicks"
+}
+
+func (d dataFile) readCounters() (map[clickKey]*clickCounter, error) {
+	counters := map[clickKey]*clickCounter{}
+	data, err := os.ReadFile(d.statsFilename())
+
+	if errors.Is(err, os.ErrNotExist) {
+		return counters, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range strings.Split(string(data), "\n") {
+		if row == "" {
+			continue
+		}
+
+		columns := strings.Split(row, " ")
+		if len(columns) != 3 && len(columns) != 4 {
+			return nil, fmt.Errorf("malformed stats row %q", row)
+		}
+
+		lastClick, err := time.Parse(time.RFC3339Nano, columns[1])
+		if err != nil {
+			return nil, err
+		}
+
+		key := clickKey{shortenURL: columns[0], bot: len(columns) == 4 && columns[3] == "bot"}
+
+		counter, ok := counters[key]
+		if !ok {
+			counter = newClickCounter()
+			counters[key] = counter
+		}
+
+		for _, day := range strings.Split(columns[2], ",") {
+			date, value, found := strings.Cut(day, ":")
+			if !found {
+				return nil, fmt.Errorf("malformed stats row %q", row)
+			}
+
+			clicks, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, err
+			}
+			counter.add(date, clicks, lastClick)
+		}
+	}
+
+	return counters, nil
+}
+
+func (d dataFile) appendCounters(counters map[clickKey]*clickCounter) error {
+	file, err := os.OpenFile(d.statsFilename(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+
+	for key, counter := range counters {
+		days := make([]string, 0, len(counter.daily))
+
+		for date, clicks := range counter.daily {
+			days = append(days, fmt.Sprintf("%s:%d", date, clicks))
+		}
+		sort.Strings(days)
+
+		row := fmt.Sprintf("%s %s %s", key.shortenURL, counter.lastClick.Format(time.RFC3339Nano), strings.Join(days, ","))
+		if key.bot {
+			row += " bot"
+		}
+
+		_, err = writer.WriteString(row + "\n")
+		if err != nil {
+			file.Close()
+			return err
+		}
+	}
+
+	err = writer.Flush()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// readClicks calls fn for every click event of shortenURL in the log along
+// with its one-based position in the log, until fn returns false.
+func (d dataFile) readClicks(shortenURL string, fn func(position int64, click Click) bool) error {
+	file, err := os.Open(d.clicksFilename())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(file))
+
+	for position := int64(1); decoder.More(); position++ {
+		var click Click
+
+		err = decoder.Decode(&click)
+		if err != nil {
+			return err
+		}
+
+		if click.ShortenURL == shortenURL && !fn(position, click) {
+			break
+		}
+	}
+
+	return nil
+}
+
+func (d dataFile) AddClicks(clicks []Click) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	counters := map[clickKey]*clickCounter{}
+
+	clicksFile, err := os.OpenFile(d.clicksFilename(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(clicksFile)
+	encoder := json.NewEncoder(writer)
+
+	for _, click := range clicks {
+		key := clickKey{shortenURL: click.ShortenURL, bot: click.Bot}
+
+		counter, ok := counters[key]
+		if !ok {
+			counter = newClickCounter()
+			counters[key] = counter
+		}
+		counter.add(click.Time.UTC().Format(dateLayout), 1, click.Time)
+
+		err = encoder.Encode(click)
+		if err != nil {
+			clicksFile.Close()
+			return err
+		}
+	}
+
+	err = writer.Flush()
+	clicksFile.Close()
+
+	if err != nil {
+		return err
+	}
+
+	return d.appendCounters(counters)
+}
+
+// checkOwner returns ErrURLNotFound unless userID owns shortenURL.
+func (d dataFile) checkOwner(shortenURL, userID string) error {
+	items, err := d.readItems()
+	if err != nil {
+		return err
+	}
+
+	owned := slices.ContainsFunc(items, func(item item) bool {
+		return item.ShortenURL == shortenURL && item.userID == userID
+	})
+	if !owned {
+		return ErrURLNotFound
+	}
+
+	return nil
+}
+
+func (d dataFile) GetStats(shortenURL, userID string) (Stats, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	err := d.checkOwner(shortenURL, userID)
+	if err != nil {
+		return Stats{}, err
+	}
+
+	counters, err := d.readCounters()
+	if err != nil {
+		return Stats{}, err
+	}
+
+	stats := newStats(counters[clickKey{shortenURL: shortenURL}], counters[clickKey{shortenURL: shortenURL, bot: true}])
+
+	err = d.readClicks(shortenURL, func(_ int64, click Click) bool {
+		stats.addBreakdown(click, 1)
+		return true
+	})
+	if err != nil {
+		return Stats{}, err
+	}
+
+	return stats, nil
+}
+
+func (d dataFile) GetClicks(shortenURL, userID string, query ClickQuery) (ClickPage, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	err := d.checkOwner(shortenURL, userID)
+	if err != nil {
+		return ClickPage{}, err
+	}
+
+	page := ClickPage{Clicks: make([]Click, 0)}
+
+	err = d.readClicks(shortenURL, func(position int64, click Click) bool {
+		return page.collect(query, position, click)
+	})
+	if err != nil {
+		return ClickPage{}, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Revisions are appended to a log next to the links file, one row per
+// replaced version: "shortenURL number replacedAt originalURL options".
+func (d dataFile) revisionsFilename() string {
+	return d.filename + ".revisions"
+}
+
+func formatRevisionRow(shortenURL string, revision Revision) (string, error) {
+	row := fmt.Sprintf("%s %d %s %s", shortenURL, revision.Number, revision.ReplacedAt.Format(time.RFC3339Nano), revision.OriginalURL)
+	values := url.Values{}
+
+	err := encodeOptions(revision.Options, values)
+	if err != nil {
+		return "", err
+	}
+
+	if len(values) > 0 {
+		row += " " + values.Encode()
+	}
+
+	return row, nil
+}
+
+func (d dataFile) readRevisions(shortenURL string) ([]Revision, error) {
+	revisions := make([]Revision, 0)
+	data, err := os.ReadFile(d.revisionsFilename())
+
+	if errors.Is(err, os.ErrNotExist) {
+		return revisions, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range strings.Split(string(data), "\n") {
+		columns := strings.Split(row, " ")
+		if columns[0] != shortenURL {
+			continue
+		}
+
+		if len(columns) != 4 && len(columns) != 5 {
+			return nil, fmt.Errorf("malformed revision row %q", row)
+		}
+
+		revision := Revision{OriginalURL: columns[3]}
+
+		revision.Number, err = strconv.Atoi(columns[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed revision row %q: %w", row, err)
+		}
+
+		revision.ReplacedAt, err = time.Parse(time.RFC3339Nano, columns[2])
+		if err != nil {
+			return nil, fmt.Errorf("malformed revision row %q: %w", row, err)
+		}
+
+		if len(columns) == 5 {
+			values, err := url.ParseQuery(columns[4])
+			if err != nil {
+				return nil, fmt.Errorf("malformed revision row %q: %w", row, err)
+			}
+
+			revision.Options, err = decodeOptions(values)
+			if err != nil {
+				return nil, fmt.Errorf("malformed revision row %q: %w", row, err)
+			}
+		}
+
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, nil
+}
+
+func (d dataFile) Update(shortenURL, userID, originalURL string, options Options) error {
+	return d.Patch(shortenURL, userID, replaceWith(originalURL, options))
+}
+
+func (d dataFile) Patch(shortenURL, userID string, patch PatchFunc) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	items, err := d.readItems()
+	if err != nil {
+		return err
+	}
+
+	index := -1
+	for i, item := range items {
+		if item.ShortenURL == shortenURL && item.userID == userID {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		return ErrURLNotFound
+	}
+
+	if items[index].status == itemStatusDeleted {
+		return ErrURLHasBeenDeleted
+	}
+
+	if items[index].status == itemStatusConsumed {
+		return ErrURLHasBeenConsumed
+	}
+
+	originalURL, options, err := patch(items[index].OriginalURL, items[index].Options)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := d.readRevisions(shortenURL)
+	if err != nil {
+		return err
+	}
+
+	row, err := formatRevisionRow(shortenURL, Revision{
+		Number:      len(revisions) + 1,
+		OriginalURL: items[index].OriginalURL,
+		Options:     items[index].Options,
+		ReplacedAt:  time.Now().UTC(),
+	})
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(d.revisionsFilename(), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0777)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	_, err = writer.WriteString(row + "\n")
+
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	err = writer.Flush()
+	file.Close()
+
+	if err != nil {
+		return err
+	}
+
+	items[index].OriginalURL = originalURL
+	items[index].Options = options
+
+	return d.writeItems(items)
+}
+
+func (d dataFile) GetRevisions(shortenURL, userID string) ([]Revision, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	err := d.checkOwner(shortenURL, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return d.readRevisions(shortenURL)
+}
@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// fileIndex keeps the search index of the links file between calls. Writes
+// through dataFile invalidate it, changes made to the file by anything else
+// are noticed by its modification time and size.
+type fileIndex struct {
+	mu      sync.Mutex
+	index   *searchIndex
+	modTime time.Time
+	size    int64
+}
+
+func (f *fileIndex) invalidate() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.index = nil
+}
+
+// search returns the short URLs matching filter, rebuilding the index from
+// items if the file has changed since it was built.
+func (f *fileIndex) search(filename string, items []item, filter Filter) (postings, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var modTime time.Time
+	var size int64
+
+	info, err := os.Stat(filename)
+	if err == nil {
+		modTime, size = info.ModTime(), info.Size()
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	if f.index == nil || !f.modTime.Equal(modTime) || f.size != size {
+		f.index = newSearchIndex()
+		for _, item := range items {
+			f.index.add(item)
+		}
+		f.modTime, f.size = modTime, size
+	}
+
+	return f.index.search(filter), nil
+}
+
+func (d dataFile) SearchItemsOfUser(userID string, filter Filter) ([]item, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	items, err := d.readItems()
+	if err != nil {
+		return nil, err
+	}
+
+	userItems := make([]item, 0)
+	matches := postings(nil)
+
+	if !filter.isEmpty() {
+		matches, err = d.index.search(d.filename, items, filter)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, item := range items {
+		if _, ok := matches[item.ShortenURL]; item.userID == userID && (matches == nil || ok) {
+			userItems = append(userItems, item)
+		}
+	}
+
+	return userItems, nil
+}
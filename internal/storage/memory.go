@@ -1,35 +1,68 @@
 package storage
 
 import (
-	"errors"
+	"sync"
+	"time"
 
+	"github.com/VadimFilimonov/urlshortener/internal/constants"
 	utils "github.com/VadimFilimonov/urlshortener/internal/utils/generateid"
 )
 
-type memoryItems map[string]item
+type memoryItems struct {
+	mu        sync.RWMutex
+	items     map[string]item
+	clicks    map[clickKey]*clickCounter
+	events    map[string][]Click
+	revisions map[string][]Revision
+	index     *searchIndex
+	// apiKeys are keyed by their hash, which every request with a key
+	// looks up.
+	apiKeys  map[string]APIKey
+	accounts map[string]Account
+	dedupe   DedupePolicy
+}
 
-func NewMemory() memoryItems {
-	return memoryItems{}
+func NewMemory(dedupe DedupePolicy) *memoryItems {
+	return &memoryItems{
+		items:     map[string]item{},
+		clicks:    map[clickKey]*clickCounter{},
+		events:    map[string][]Click{},
+		revisions: map[string][]Revision{},
+		index:     newSearchIndex(),
+		apiKeys:   map[string]APIKey{},
+		accounts:  map[string]Account{},
+		dedupe:    dedupe,
+	}
 }
 
-func (items memoryItems) Get(shortenURL string) (string, error) {
-	item, ok := items[shortenURL]
+func (m *memoryItems) Get(shortenURL string) (item, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	existingItem, ok := m.items[shortenURL]
 
 	if !ok {
-		return "", errors.New("incorrect shortenURL")
+		return item{}, ErrURLNotFound
+	}
+
+	if existingItem.status == itemStatusDeleted {
+		return item{}, ErrURLHasBeenDeleted
 	}
 
-	if item.status == itemStatusDeleted {
-		return "", ErrURLHasBeenDeleted
+	if existingItem.status == itemStatusConsumed {
+		return item{}, ErrURLHasBeenConsumed
 	}
 
-	return item.OriginalURL, nil
+	return existingItem, nil
 }
 
-func (items memoryItems) GetItemsOfUser(userID string) ([]item, error) {
+func (m *memoryItems) GetItemsOfUser(userID string) ([]item, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	userItems := make([]item, 0)
 
-	for _, item := range items {
+	for _, item := range m.items {
 		if item.userID == userID {
 			userItems = append(userItems, item)
 		}
@@ -38,27 +71,319 @@ func (items memoryItems) GetItemsOfUser(userID string) ([]item, error) {
 	return userItems, nil
 }
 
-func (items memoryItems) Add(originalURL, userID string) (string, error) {
-	shortenURLPath := utils.GenerateID()
+func (m *memoryItems) SearchItemsOfUser(userID string, filter Filter) ([]item, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	userItems := make([]item, 0)
+
+	if filter.isEmpty() {
+		for _, item := range m.items {
+			if item.userID == userID {
+				userItems = append(userItems, item)
+			}
+		}
+		return userItems, nil
+	}
+
+	for shortenURL := range m.index.search(filter) {
+		if item := m.items[shortenURL]; item.userID == userID {
+			userItems = append(userItems, item)
+		}
+	}
+
+	return userItems, nil
+}
+
+func (m *memoryItems) Add(originalURL, userID string, options Options) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	dedupe := m.dedupe.dedupeFor(options)
 
-	items[shortenURLPath] = item{
+	for _, item := range m.items {
+		if dedupe.isDuplicate(item, originalURL, userID) {
+			return item.ShortenURL, constants.ErrURLAlreadyExists
+		}
+	}
+
+	shortenURLPath, err := utils.GenerateID()
+	if err != nil {
+		return "", err
+	}
+
+	m.items[shortenURLPath] = item{
 		userID:      userID,
 		ShortenURL:  shortenURLPath,
 		OriginalURL: originalURL,
 		status:      itemStatusCreated,
+		CreatedAt:   time.Now().UTC(),
+		Options:     options,
 	}
+	m.index.add(m.items[shortenURLPath])
 
 	return shortenURLPath, nil
 }
 
-func (items memoryItems) Delete(ids []string, userID string) error {
+func (m *memoryItems) Delete(ids []string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	for _, id := range ids {
-		itemCopy := items[id]
+		itemCopy, ok := m.items[id]
 
-		if itemCopy.userID == userID {
+		if ok && itemCopy.userID == userID {
 			itemCopy.status = itemStatusDeleted
-			items[id] = itemCopy
+			m.items[id] = itemCopy
+		}
+	}
+	return nil
+}
+
+func (m *memoryItems) AddClicks(clicks []Click) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, click := range clicks {
+		key := clickKey{shortenURL: click.ShortenURL, bot: click.Bot}
+
+		counter, ok := m.clicks[key]
+		if !ok {
+			counter = newClickCounter()
+			m.clicks[key] = counter
+		}
+		counter.add(click.Time.UTC().Format(dateLayout), 1, click.Time)
+		m.events[click.ShortenURL] = append(m.events[click.ShortenURL], click)
+	}
+
+	return nil
+}
+
+func (m *memoryItems) GetStats(shortenURL, userID string) (Stats, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.items[shortenURL]
+	if !ok || item.userID != userID {
+		return Stats{}, ErrURLNotFound
+	}
+
+	stats := newStats(m.clicks[clickKey{shortenURL: shortenURL}], m.clicks[clickKey{shortenURL: shortenURL, bot: true}])
+
+	for _, click := range m.events[shortenURL] {
+		stats.addBreakdown(click, 1)
+	}
+
+	return stats, nil
+}
+
+func (m *memoryItems) GetClicks(shortenURL, userID string, query ClickQuery) (ClickPage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.items[shortenURL]
+	if !ok || item.userID != userID {
+		return ClickPage{}, ErrURLNotFound
+	}
+
+	page := ClickPage{Clicks: make([]Click, 0)}
+
+	for i, click := range m.events[shortenURL] {
+		if !page.collect(query, int64(i+1), click) {
+			break
 		}
 	}
+
+	return page, nil
+}
+
+func (m *memoryItems) Update(shortenURL, userID, originalURL string, options Options) error {
+	return m.Patch(shortenURL, userID, replaceWith(originalURL, options))
+}
+
+func (m *memoryItems) Patch(shortenURL, userID string, patch PatchFunc) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existingItem, ok := m.items[shortenURL]
+	if !ok || existingItem.userID != userID {
+		return ErrURLNotFound
+	}
+
+	if existingItem.status == itemStatusDeleted {
+		return ErrURLHasBeenDeleted
+	}
+
+	if existingItem.status == itemStatusConsumed {
+		return ErrURLHasBeenConsumed
+	}
+
+	originalURL, options, err := patch(existingItem.OriginalURL, existingItem.Options)
+	if err != nil {
+		return err
+	}
+
+	m.revisions[shortenURL] = append(m.revisions[shortenURL], Revision{
+		Number:      len(m.revisions[shortenURL]) + 1,
+		OriginalURL: existingItem.OriginalURL,
+		Options:     existingItem.Options,
+		ReplacedAt:  time.Now().UTC(),
+	})
+
+	existingItem.OriginalURL = originalURL
+	existingItem.Options = options
+	m.items[shortenURL] = existingItem
+	m.index.add(existingItem)
+
 	return nil
 }
+
+func (m *memoryItems) GetRevisions(shortenURL, userID string) ([]Revision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	item, ok := m.items[shortenURL]
+	if !ok || item.userID != userID {
+		return nil, ErrURLNotFound
+	}
+
+	revisions := make([]Revision, len(m.revisions[shortenURL]))
+	copy(revisions, m.revisions[shortenURL])
+
+	return revisions, nil
+}
+
+func (m *memoryItems) Consume(shortenURL string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existingItem, ok := m.items[shortenURL]
+
+	switch {
+	case !ok:
+		return ErrURLNotFound
+	case existingItem.status == itemStatusDeleted:
+		return ErrURLHasBeenDeleted
+	case existingItem.status == itemStatusConsumed:
+		return ErrURLHasBeenConsumed
+	}
+
+	existingItem.status = itemStatusConsumed
+	m.items[shortenURL] = existingItem
+
+	return nil
+}
+
+func (m *memoryItems) AddAPIKey(key APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.apiKeys[key.Hash] = key
+
+	return nil
+}
+
+func (m *memoryItems) GetAPIKeys(userID string) ([]APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]APIKey, 0)
+
+	for _, key := range m.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+func (m *memoryItems) GetAPIKeyByHash(hash string) (APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[hash]
+	if !ok {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+func (m *memoryItems) RevokeAPIKey(id, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for hash, key := range m.apiKeys {
+		if key.ID != id || key.UserID != userID {
+			continue
+		}
+
+		if key.RevokedAt == nil {
+			revokedAt := time.Now().UTC()
+			key.RevokedAt = &revokedAt
+			m.apiKeys[hash] = key
+		}
+
+		return nil
+	}
+
+	return ErrAPIKeyNotFound
+}
+
+func (m *memoryItems) AddAccount(account Account) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.accounts {
+		if existing.Email == account.Email {
+			return ErrEmailTaken
+		}
+	}
+
+	m.accounts[account.ID] = account
+
+	return nil
+}
+
+func (m *memoryItems) GetAccount(id string) (Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	account, ok := m.accounts[id]
+	if !ok {
+		return Account{}, ErrAccountNotFound
+	}
+
+	return account, nil
+}
+
+func (m *memoryItems) GetAccountByEmail(email string) (Account, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, account := range m.accounts {
+		if account.Email == email {
+			return account, nil
+		}
+	}
+
+	return Account{}, ErrAccountNotFound
+}
+
+func (m *memoryItems) ClaimItems(fromUserID, toUserID string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	claimed := 0
+
+	for shortenURL, item := range m.items {
+		if item.userID == fromUserID {
+			item.userID = toUserID
+			m.items[shortenURL] = item
+			claimed++
+		}
+	}
+
+	return claimed, nil
+}
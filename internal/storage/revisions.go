@@ -0,0 +1,12 @@
+package storage
+
+import "time"
+
+// Revision is a past version of a link, kept when Update replaced it.
+// Revisions of a link are numbered from 1 in the order they were replaced.
+type Revision struct {
+	Number      int
+	OriginalURL string
+	Options
+	ReplacedAt time.Time
+}
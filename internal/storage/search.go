@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/exp/slices"
+)
+
+// Filter narrows the links of a user in SearchItemsOfUser. The zero Filter
+// matches every link.
+type Filter struct {
+	// Every word of Query must be a prefix of a word of the original URL,
+	// the title or a tag.
+	Query string
+	// Tags must all be set on the link.
+	Tags []string
+	// Folder, if not nil, must equal the folder of the link. An empty one
+	// matches links outside of any folder.
+	Folder *string
+}
+
+func (f Filter) isEmpty() bool {
+	return len(searchWords(f.Query)) == 0 && len(f.Tags) == 0 && f.Folder == nil
+}
+
+// searchWords splits text into lowercase words of letters and digits, so a
+// URL like https://example.com/go-tips gives https, example, com, go and
+// tips.
+func searchWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// searchText is the text a link is found by.
+func searchText(originalURL string, options Options) string {
+	return strings.Join(searchWords(originalURL+" "+options.Title+" "+strings.Join(options.Tags, " ")), " ")
+}
+
+type postings map[string]struct{}
+
+// searchIndex is the in-memory counterpart of the Postgres indexes on urls:
+// it maps words, tags and folders to the short URLs having them. Words are
+// also kept sorted to find those starting with a prefix. It is not safe for
+// concurrent use.
+type searchIndex struct {
+	words   map[string]postings
+	sorted  []string
+	tags    map[string]postings
+	folders map[string]postings
+	// entries remember what every link was indexed under to remove it.
+	entries map[string]indexEntry
+}
+
+type indexEntry struct {
+	words  []string
+	tags   []string
+	folder string
+}
+
+func newSearchIndex() *searchIndex {
+	return &searchIndex{
+		words:   map[string]postings{},
+		tags:    map[string]postings{},
+		folders: map[string]postings{},
+		entries: map[string]indexEntry{},
+	}
+}
+
+// add indexes item, replacing what it was indexed under before.
+func (index *searchIndex) add(item item) {
+	index.remove(item.ShortenURL)
+
+	words := searchWords(searchText(item.OriginalURL, item.Options))
+	slices.Sort(words)
+
+	entry := indexEntry{
+		words:  slices.Compact(words),
+		tags:   item.Tags,
+		folder: item.Folder,
+	}
+
+	for _, word := range entry.words {
+		if _, ok := index.words[word]; !ok {
+			index.words[word] = postings{}
+			position, _ := slices.BinarySearch(index.sorted, word)
+			index.sorted = slices.Insert(index.sorted, position, word)
+		}
+		index.words[word][item.ShortenURL] = struct{}{}
+	}
+
+	for _, tag := range entry.tags {
+		addPosting(index.tags, tag, item.ShortenURL)
+	}
+
+	addPosting(index.folders, entry.folder, item.ShortenURL)
+	index.entries[item.ShortenURL] = entry
+}
+
+func (index *searchIndex) remove(shortenURL string) {
+	entry, ok := index.entries[shortenURL]
+	if !ok {
+		return
+	}
+
+	for _, word := range entry.words {
+		if removePosting(index.words, word, shortenURL) {
+			position, _ := slices.BinarySearch(index.sorted, word)
+			index.sorted = slices.Delete(index.sorted, position, position+1)
+		}
+	}
+
+	for _, tag := range entry.tags {
+		removePosting(index.tags, tag, shortenURL)
+	}
+
+	removePosting(index.folders, entry.folder, shortenURL)
+	delete(index.entries, shortenURL)
+}
+
+// search returns the short URLs matching filter, whoever owns them.
+func (index *searchIndex) search(filter Filter) postings {
+	var matches postings
+
+	for _, tag := range filter.Tags {
+		matches = intersect(matches, index.tags[tag])
+	}
+
+	if filter.Folder != nil {
+		matches = intersect(matches, index.folders[*filter.Folder])
+	}
+
+	for _, word := range searchWords(filter.Query) {
+		matches = intersect(matches, index.prefixed(word))
+	}
+
+	return matches
+}
+
+// prefixed returns the short URLs having a word that starts with prefix.
+func (index *searchIndex) prefixed(prefix string) postings {
+	found := postings{}
+
+	for i := sort.SearchStrings(index.sorted, prefix); i < len(index.sorted) && strings.HasPrefix(index.sorted[i], prefix); i++ {
+		for shortenURL := range index.words[index.sorted[i]] {
+			found[shortenURL] = struct{}{}
+		}
+	}
+
+	return found
+}
+
+func addPosting(index map[string]postings, key, shortenURL string) {
+	if _, ok := index[key]; !ok {
+		index[key] = postings{}
+	}
+	index[key][shortenURL] = struct{}{}
+}
+
+// removePosting reports whether key has no short URLs left and was dropped.
+func removePosting(index map[string]postings, key, shortenURL string) bool {
+	delete(index[key], shortenURL)
+
+	if len(index[key]) > 0 {
+		return false
+	}
+
+	delete(index, key)
+	return true
+}
+
+// intersect returns the short URLs in both a and b, a nil a stands for all of
+// them.
+func intersect(a, b postings) postings {
+	result := postings{}
+
+	for shortenURL := range b {
+		if _, ok := a[shortenURL]; a == nil || ok {
+			result[shortenURL] = struct{}{}
+		}
+	}
+
+	return result
+}
@@ -2,15 +2,64 @@ package storage
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/VadimFilimonov/urlshortener/internal/config"
+	"github.com/VadimFilimonov/urlshortener/internal/split"
+	"github.com/VadimFilimonov/urlshortener/internal/targeting"
+	"github.com/VadimFilimonov/urlshortener/internal/utm"
 )
 
 type Data interface {
-	Get(shortenURL string) (string, error)
+	Get(shortenURL string) (item, error)
 	GetItemsOfUser(userID string) ([]item, error)
-	Add(originalURL, userID string) (shortenURL string, err error)
+	SearchItemsOfUser(userID string, filter Filter) ([]item, error)
+	Add(originalURL, userID string, options Options) (shortenURL string, err error)
 	Delete(ids []string, userID string) error
+	AddClicks(clicks []Click) error
+	GetStats(shortenURL, userID string) (Stats, error)
+	GetClicks(shortenURL, userID string, query ClickQuery) (ClickPage, error)
+	// Update replaces the destination and options of a link owned by userID,
+	// keeping the replaced version as a revision.
+	Update(shortenURL, userID, originalURL string, options Options) error
+	// Patch is Update with the new version computed by patch from the
+	// current one. Patch is only called for the owner, and no other change
+	// of the link can happen in between. An error of patch is returned as
+	// is and leaves the link unchanged.
+	Patch(shortenURL, userID string, patch PatchFunc) error
+	GetRevisions(shortenURL, userID string) ([]Revision, error)
+	// Consume marks a one-time link as used. Of concurrent calls for the
+	// same link exactly one succeeds, the others get ErrURLHasBeenConsumed.
+	Consume(shortenURL string) error
+	AddAPIKey(key APIKey) error
+	// GetAPIKeys lists the keys of a user, revoked ones included.
+	GetAPIKeys(userID string) ([]APIKey, error)
+	// GetAPIKeyByHash returns revoked keys as well, checking RevokedAt is up
+	// to the caller.
+	GetAPIKeyByHash(hash string) (APIKey, error)
+	// RevokeAPIKey revokes a key owned by userID. Revoking it again keeps
+	// the time of the first revocation.
+	RevokeAPIKey(id, userID string) error
+	// AddAccount returns ErrEmailTaken if there is an account with the
+	// email already.
+	AddAccount(account Account) error
+	GetAccount(id string) (Account, error)
+	GetAccountByEmail(email string) (Account, error)
+	// ClaimItems hands the links of fromUserID over to toUserID and returns
+	// how many there were.
+	ClaimItems(fromUserID, toUserID string) (int, error)
+}
+
+// PatchFunc returns the new destination and options of a link from the
+// current ones.
+type PatchFunc func(originalURL string, options Options) (string, Options, error)
+
+// replaceWith is the PatchFunc of Update.
+func replaceWith(originalURL string, options Options) PatchFunc {
+	return func(string, Options) (string, Options, error) {
+		return originalURL, options, nil
+	}
 }
 
 type item struct {
@@ -18,16 +67,129 @@ type item struct {
 	ShortenURL  string `json:"short_url"`
 	OriginalURL string `json:"original_url"`
 	status      string
+	// CreatedAt is zero for links created before it was stored.
+	CreatedAt time.Time
+	Options
+}
+
+// Options are attributes of a link chosen when it is created.
+type Options struct {
+	// RedirectType is the HTTP status used to redirect, 0 means the server
+	// default.
+	RedirectType int
+	// PassQuery merges the query of the visited short URL into the
+	// destination.
+	PassQuery bool
+	// PassPath appends the path after the short code to the destination.
+	PassPath bool
+	// UTM parameters are added to the destination on redirect, the stored
+	// OriginalURL stays untouched.
+	UTM utm.Params
+	// Rules pick another destination depending on the visitor, OriginalURL
+	// is used when none matches.
+	Rules targeting.Rules
+	// Variants split visitors no rule matched across weighted destinations
+	// instead of OriginalURL.
+	Variants split.Variants
+	// StickyVariants keeps a visitor on the variant they got first.
+	StickyVariants bool
+	// PasswordHash is a bcrypt hash of the password a visitor must enter
+	// before being redirected, empty for public links.
+	PasswordHash string
+	// OneTime links stop working after the first redirect.
+	OneTime bool
+	// ActivatesAt is when the link starts redirecting to its destination, nil
+	// for links active from the start. Until then visitors are sent to
+	// FallbackURL if there is one.
+	ActivatesAt *time.Time
+	FallbackURL string
+	// Title is shown on the preview page, ForcePreview shows that page to
+	// every visitor before the redirect.
+	Title        string
+	ForcePreview bool
+	// Tags and Folder organize the links of a user. Tags are kept sorted,
+	// links without a folder have an empty one.
+	Tags   []string
+	Folder string
+}
+
+// isZero reports whether o are the options of a plain link.
+func (o Options) isZero() bool {
+	return o.RedirectType == 0 && !o.PassQuery && !o.PassPath && o.UTM.IsZero() &&
+		len(o.Rules) == 0 && len(o.Variants) == 0 && !o.StickyVariants && o.PasswordHash == "" &&
+		!o.OneTime && o.ActivatesAt == nil && o.FallbackURL == "" && o.Title == "" && !o.ForcePreview &&
+		len(o.Tags) == 0 && o.Folder == ""
+}
+
+// IsActive reports whether the link redirects to its destination at now.
+func (o Options) IsActive(now time.Time) bool {
+	return o.ActivatesAt == nil || !now.Before(*o.ActivatesAt)
 }
 
 const (
-	itemStatusCreated = "created"
-	itemStatusDeleted = "deleted"
+	itemStatusCreated  = "created"
+	itemStatusDeleted  = "deleted"
+	itemStatusConsumed = "consumed"
+)
+
+var (
+	ErrURLHasBeenDeleted  = errors.New("url has been deleted")
+	ErrURLNotFound        = errors.New("url not found")
+	ErrURLHasBeenConsumed = errors.New("one-time url has already been used")
+)
+
+// DedupePolicy decides when Add returns an existing short URL together with
+// constants.ErrURLAlreadyExists instead of creating a new one.
+type DedupePolicy string
+
+const (
+	// DedupeNone always creates a new short URL.
+	DedupeNone DedupePolicy = "none"
+	// DedupeUser reuses a short URL created by the same user.
+	DedupeUser DedupePolicy = "user"
+	// DedupeGlobal reuses a short URL created by anyone.
+	DedupeGlobal DedupePolicy = "global"
 )
 
-var ErrURLHasBeenDeleted = errors.New("url has been deleted")
+func ParseDedupePolicy(value string) (DedupePolicy, error) {
+	switch policy := DedupePolicy(value); policy {
+	case DedupeNone, DedupeUser, DedupeGlobal:
+		return policy, nil
+	case "":
+		return DedupeGlobal, nil
+	default:
+		return "", fmt.Errorf("unknown dedupe policy %q", value)
+	}
+}
+
+// dedupeFor returns the policy for adding a link with options. Only plain
+// links are shared: a link with options, such as a password, variants or a
+// one-time link, is neither reused nor handed out for another link.
+func (policy DedupePolicy) dedupeFor(options Options) DedupePolicy {
+	if !options.isZero() {
+		return DedupeNone
+	}
+
+	return policy
+}
+
+// isDuplicate reports whether adding a plain link to originalURL for userID
+// should reuse existing. Deleted and consumed items and items with options
+// are never reused.
+func (policy DedupePolicy) isDuplicate(existing item, originalURL, userID string) bool {
+	if policy == DedupeNone || existing.status != itemStatusCreated || !existing.Options.isZero() || existing.OriginalURL != originalURL {
+		return false
+	}
+
+	return policy == DedupeGlobal || existing.userID == userID
+}
 
 func GetStorage(config config.Config) (Data, error) {
+	dedupe, err := ParseDedupePolicy(config.DedupePolicy)
+	if err != nil {
+		return nil, err
+	}
+
 	if config.DatabaseDNS != "" {
 		db, err := InitDB(config.DatabaseDNS)
 
@@ -35,12 +197,12 @@ func GetStorage(config config.Config) (Data, error) {
 			return nil, err
 		}
 
-		return NewDB(db), nil
+		return NewDB(db, dedupe), nil
 	}
 
 	if config.FileStoragePath != "" {
-		return NewFile(config.FileStoragePath), nil
+		return NewFile(config.FileStoragePath, dedupe), nil
 	}
 
-	return NewMemory(), nil
+	return NewMemory(dedupe), nil
 }
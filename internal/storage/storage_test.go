@@ -0,0 +1,531 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/VadimFilimonov/urlshortener/internal/constants"
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
+	"github.com/VadimFilimonov/urlshortener/internal/split"
+	"github.com/VadimFilimonov/urlshortener/internal/targeting"
+	"github.com/VadimFilimonov/urlshortener/internal/useragent"
+	"github.com/VadimFilimonov/urlshortener/internal/utm"
+)
+
+func TestAddDedupe(t *testing.T) {
+	const originalURL = "https://filimonovvadim.t.me"
+
+	tests := []struct {
+		name            string
+		dedupe          DedupePolicy
+		sameUserExists  bool
+		otherUserExists bool
+	}{
+		{
+			name:            "None",
+			dedupe:          DedupeNone,
+			sameUserExists:  false,
+			otherUserExists: false,
+		},
+		{
+			name:            "Per user",
+			dedupe:          DedupeUser,
+			sameUserExists:  true,
+			otherUserExists: false,
+		},
+		{
+			name:            "Global",
+			dedupe:          DedupeGlobal,
+			sameUserExists:  true,
+			otherUserExists: true,
+		},
+	}
+
+	for _, tt := range tests {
+		backends := map[string]Data{
+			"memory": NewMemory(tt.dedupe),
+			"file":   NewFile(filepath.Join(t.TempDir(), "urls"), tt.dedupe),
+		}
+
+		for backend, data := range backends {
+			t.Run(tt.name+" "+backend, func(t *testing.T) {
+				first, err := data.Add(originalURL, "user1", Options{})
+				require.NoError(t, err)
+
+				second, err := data.Add(originalURL, "user1", Options{})
+				assert.Equal(t, tt.sameUserExists, errors.Is(err, constants.ErrURLAlreadyExists))
+				assert.Equal(t, tt.sameUserExists, first == second)
+
+				third, err := data.Add(originalURL, "user2", Options{})
+				assert.Equal(t, tt.otherUserExists, errors.Is(err, constants.ErrURLAlreadyExists))
+				assert.Equal(t, tt.otherUserExists, first == third)
+
+				err = data.Delete([]string{first}, "user1")
+				require.NoError(t, err)
+
+				if tt.otherUserExists {
+					_, err = data.Add(originalURL, "user2", Options{})
+					assert.NoError(t, err, "deleted url must not be reused")
+				}
+			})
+		}
+	}
+}
+
+func TestAddDedupeOptions(t *testing.T) {
+	const originalURL = "https://x.com"
+
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeGlobal),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeGlobal),
+	}
+
+	variants := split.Variants{{Name: "a", URL: "https://evil.com", Weight: 1}}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			alice, err := data.Add(originalURL, "alice", Options{PasswordHash: "$2a$10$hash", Variants: variants})
+			require.NoError(t, err)
+
+			bob, err := data.Add(originalURL, "bob", Options{})
+			require.NoError(t, err, "a link with options must not be handed out for a plain one")
+			assert.NotEqual(t, alice, bob)
+
+			carol, err := data.Add(originalURL, "carol", Options{RedirectType: 301})
+			require.NoError(t, err, "a plain link must not be reused for one with options")
+			assert.NotEqual(t, bob, carol)
+			assert.NotEqual(t, alice, carol)
+
+			dave, err := data.Add(originalURL, "dave", Options{})
+			assert.ErrorIs(t, err, constants.ErrURLAlreadyExists)
+			assert.Equal(t, bob, dave, "plain links are still shared")
+
+			item, err := data.Get(dave)
+			require.NoError(t, err)
+			assert.True(t, item.Options.isZero())
+		})
+	}
+}
+
+func TestAddOptions(t *testing.T) {
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	options := Options{
+		RedirectType: 301,
+		PassQuery:    true,
+		PassPath:     true,
+		UTM:          utm.Params{Source: "news letter", Campaign: "spring&sale"},
+		Rules: targeting.Rules{
+			{OS: []string{"iOS"}, URL: "https://apps.apple.com/app?id=1 2"},
+			{Countries: []string{"DE"}, Languages: []string{"de"}, From: &from, URL: "https://example.de"},
+		},
+		Variants: split.Variants{
+			{Name: "a", URL: "https://example.com/a", Weight: 1},
+			{Name: "b", URL: "https://example.com/b", Weight: 3},
+		},
+		StickyVariants: true,
+		PasswordHash:   "$2a$10$N9qo8uLOickgx2ZMRZoMyeIjZAgcfl7p92ldGxad68LJZdL17lhWy",
+		ActivatesAt:    &from,
+		FallbackURL:    "https://filimonovvadim.t.me/soon?from=1&to=2",
+		Title:          "Spring sale: 20% off",
+		ForcePreview:   true,
+		Tags:           []string{"go", "sale & more"},
+		Folder:         "blog/2026",
+	}
+
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeNone),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeNone),
+	}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			shortenURL, err := data.Add("https://filimonovvadim.t.me", "user1", options)
+			require.NoError(t, err)
+
+			item, err := data.Get(shortenURL)
+			require.NoError(t, err)
+			assert.Equal(t, options, item.Options)
+			assert.False(t, item.CreatedAt.IsZero())
+		})
+	}
+}
+
+func TestFileLegacyRows(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "urls")
+	err := os.WriteFile(filename, []byte("abcdef https://filimonovvadim.t.me user1 created\n"), 0600)
+	require.NoError(t, err)
+
+	item, err := NewFile(filename, DedupeNone).Get("abcdef")
+	require.NoError(t, err)
+	assert.Equal(t, "https://filimonovvadim.t.me", item.OriginalURL)
+	assert.Equal(t, Options{}, item.Options)
+}
+
+func TestStats(t *testing.T) {
+	firstDay := time.Date(2026, 10, 18, 23, 30, 0, 0, time.UTC)
+	secondDay := firstDay.Add(time.Hour)
+	browser := useragent.Classify("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeNone),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeNone),
+	}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			shortenURL, err := data.Add("https://filimonovvadim.t.me", "user1", Options{})
+			require.NoError(t, err)
+
+			err = data.AddClicks([]Click{{ShortenURL: shortenURL, Time: firstDay, Info: browser, Location: geoip.Location{Country: "RU", Region: "Moscow"}}})
+			require.NoError(t, err)
+			err = data.AddClicks([]Click{{ShortenURL: shortenURL, Time: secondDay, Info: browser, Variant: "a"}, {ShortenURL: shortenURL, Time: firstDay, Info: browser, Variant: "a"}})
+			require.NoError(t, err)
+			err = data.AddClicks([]Click{{ShortenURL: shortenURL, Time: secondDay.Add(time.Minute), Info: useragent.Classify("curl/8.4.0")}})
+			require.NoError(t, err)
+
+			stats, err := data.GetStats(shortenURL, "user1")
+			require.NoError(t, err)
+			assert.Equal(t, 3, stats.TotalClicks)
+			require.NotNil(t, stats.LastClick)
+			assert.True(t, secondDay.Equal(*stats.LastClick))
+			assert.Equal(t, []DailyClicks{{Date: "2026-10-18", Clicks: 2}, {Date: "2026-10-19", Clicks: 1}}, stats.Daily)
+			assert.Equal(t, 1, stats.Bots.TotalClicks)
+			assert.Equal(t, map[string]int{"desktop": 3}, stats.Devices)
+			assert.Equal(t, map[string]int{"Chrome": 3}, stats.Browsers)
+			assert.Equal(t, map[string]int{"RU": 1, "": 2}, stats.Countries)
+			assert.Equal(t, map[string]int{"a": 2}, stats.Variants)
+
+			withBots := stats.WithBots()
+			assert.Equal(t, 4, withBots.TotalClicks)
+			assert.True(t, secondDay.Add(time.Minute).Equal(*withBots.LastClick))
+			assert.Equal(t, []DailyClicks{{Date: "2026-10-18", Clicks: 2}, {Date: "2026-10-19", Clicks: 2}}, withBots.Daily)
+
+			_, err = data.GetStats(shortenURL, "user2")
+			assert.ErrorIs(t, err, ErrURLNotFound)
+		})
+	}
+}
+
+func TestGetClicks(t *testing.T) {
+	start := time.Date(2026, 10, 19, 10, 0, 0, 0, time.UTC)
+
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeNone),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeNone),
+	}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			shortenURL, err := data.Add("https://filimonovvadim.t.me", "user1", Options{})
+			require.NoError(t, err)
+
+			clicks := []Click{
+				{ShortenURL: shortenURL, Time: start, UserAgent: "Mozilla/5.0 (X11; Linux x86_64)", IP: "192.168.10.0"},
+				{ShortenURL: shortenURL, Time: start.Add(time.Hour), Referer: "https://t.me/"},
+				{ShortenURL: "other", Time: start.Add(time.Hour)},
+				{ShortenURL: shortenURL, Time: start.Add(2 * time.Hour), AcceptLanguage: "ru-RU,ru;q=0.9"},
+			}
+			err = data.AddClicks(clicks)
+			require.NoError(t, err)
+
+			all, err := data.GetClicks(shortenURL, "user1", ClickQuery{})
+			require.NoError(t, err)
+			assert.Len(t, all.Clicks, 3)
+			assert.Equal(t, clicks[0].UserAgent, all.Clicks[0].UserAgent)
+			assert.Zero(t, all.Next)
+
+			ranged, err := data.GetClicks(shortenURL, "user1", ClickQuery{From: start.Add(time.Hour), To: start.Add(2 * time.Hour)})
+			require.NoError(t, err)
+			require.Len(t, ranged.Clicks, 1)
+			assert.Equal(t, "https://t.me/", ranged.Clicks[0].Referer)
+
+			first, err := data.GetClicks(shortenURL, "user1", ClickQuery{Limit: 2})
+			require.NoError(t, err)
+			require.Len(t, first.Clicks, 2)
+			assert.NotZero(t, first.Next)
+
+			second, err := data.GetClicks(shortenURL, "user1", ClickQuery{Cursor: first.Next, Limit: 2})
+			require.NoError(t, err)
+			require.Len(t, second.Clicks, 1)
+			assert.Equal(t, clicks[3].AcceptLanguage, second.Clicks[0].AcceptLanguage)
+			assert.Zero(t, second.Next)
+
+			_, err = data.GetClicks(shortenURL, "user2", ClickQuery{})
+			assert.ErrorIs(t, err, ErrURLNotFound)
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeNone),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeNone),
+	}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			shortenURL, err := data.Add("https://filimonovvadim.t.me/typo", "user1", Options{PassQuery: true})
+			require.NoError(t, err)
+			otherURL, err := data.Add("https://filimonovvadim.t.me/other", "user1", Options{})
+			require.NoError(t, err)
+
+			err = data.Update(shortenURL, "user2", "https://example.com", Options{})
+			assert.ErrorIs(t, err, ErrURLNotFound)
+
+			err = data.Update(shortenURL, "user1", "https://filimonovvadim.t.me/fixed", Options{RedirectType: 301})
+			require.NoError(t, err)
+			err = data.Update(shortenURL, "user1", "https://filimonovvadim.t.me/final", Options{})
+			require.NoError(t, err)
+
+			item, err := data.Get(shortenURL)
+			require.NoError(t, err)
+			assert.Equal(t, "https://filimonovvadim.t.me/final", item.OriginalURL)
+
+			item, err = data.Get(otherURL)
+			require.NoError(t, err)
+			assert.Equal(t, "https://filimonovvadim.t.me/other", item.OriginalURL)
+
+			revisions, err := data.GetRevisions(shortenURL, "user1")
+			require.NoError(t, err)
+			require.Len(t, revisions, 2)
+			assert.Equal(t, 1, revisions[0].Number)
+			assert.Equal(t, "https://filimonovvadim.t.me/typo", revisions[0].OriginalURL)
+			assert.Equal(t, Options{PassQuery: true}, revisions[0].Options)
+			assert.Equal(t, 2, revisions[1].Number)
+			assert.Equal(t, Options{RedirectType: 301}, revisions[1].Options)
+
+			revisions, err = data.GetRevisions(otherURL, "user1")
+			require.NoError(t, err)
+			assert.Empty(t, revisions)
+
+			_, err = data.GetRevisions(shortenURL, "user2")
+			assert.ErrorIs(t, err, ErrURLNotFound)
+
+			err = data.Delete([]string{shortenURL}, "user1")
+			require.NoError(t, err)
+			err = data.Update(shortenURL, "user1", "https://example.com", Options{})
+			assert.ErrorIs(t, err, ErrURLHasBeenDeleted)
+		})
+	}
+}
+
+func TestSearchItemsOfUser(t *testing.T) {
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeNone),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeNone),
+	}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			goTips, err := data.Add("https://example.com/go-tips", "user1", Options{Title: "Concurrency patterns", Tags: []string{"go", "talks"}, Folder: "blog"})
+			require.NoError(t, err)
+			rustBook, err := data.Add("https://example.org/rust", "user1", Options{Tags: []string{"rust"}, Folder: "blog"})
+			require.NoError(t, err)
+			news, err := data.Add("https://news.example.com", "user1", Options{})
+			require.NoError(t, err)
+			_, err = data.Add("https://example.com/go-tips", "user2", Options{Tags: []string{"go"}})
+			require.NoError(t, err)
+
+			blog, root := "blog", ""
+			search := func(filter Filter) []string {
+				items, err := data.SearchItemsOfUser("user1", filter)
+				require.NoError(t, err)
+
+				shortenURLs := make([]string, len(items))
+				for i, item := range items {
+					shortenURLs[i] = item.ShortenURL
+				}
+				return shortenURLs
+			}
+
+			assert.ElementsMatch(t, []string{goTips, rustBook, news}, search(Filter{}))
+			assert.ElementsMatch(t, []string{goTips, news}, search(Filter{Query: "EXAMPLE.com"}))
+			assert.ElementsMatch(t, []string{goTips}, search(Filter{Query: "concur tip"}))
+			assert.ElementsMatch(t, []string{goTips}, search(Filter{Query: "talk"}))
+			assert.Empty(t, search(Filter{Query: "python"}))
+			assert.ElementsMatch(t, []string{goTips}, search(Filter{Tags: []string{"go", "talks"}}))
+			assert.Empty(t, search(Filter{Tags: []string{"go", "rust"}}))
+			assert.ElementsMatch(t, []string{goTips, rustBook}, search(Filter{Folder: &blog}))
+			assert.ElementsMatch(t, []string{news}, search(Filter{Folder: &root}))
+			assert.ElementsMatch(t, []string{rustBook}, search(Filter{Query: "example", Tags: []string{"rust"}, Folder: &blog}))
+
+			err = data.Update(goTips, "user1", "https://example.com/python-tips", Options{Tags: []string{"python"}})
+			require.NoError(t, err)
+
+			assert.ElementsMatch(t, []string{goTips}, search(Filter{Query: "python"}))
+			assert.Empty(t, search(Filter{Query: "concurrency"}))
+			assert.Empty(t, search(Filter{Tags: []string{"go"}}))
+			assert.ElementsMatch(t, []string{rustBook}, search(Filter{Folder: &blog}))
+		})
+	}
+}
+
+func TestAPIKeys(t *testing.T) {
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeNone),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeNone),
+	}
+
+	createdAt := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	key := APIKey{
+		ID:        "k1",
+		UserID:    "user1",
+		Name:      "CI & deploys",
+		Prefix:    "usk_abcdef",
+		Hash:      "5e884898da28047151d0e56f8dc6292773603d0d6aabbdd62a11ef721d1542d8",
+		Scopes:    []string{"read", "shorten"},
+		CreatedAt: createdAt,
+	}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			require.NoError(t, data.AddAPIKey(key))
+			require.NoError(t, data.AddAPIKey(APIKey{ID: "k2", UserID: "user2", Hash: "other", Scopes: []string{"read"}, CreatedAt: createdAt}))
+
+			found, err := data.GetAPIKeyByHash(key.Hash)
+			require.NoError(t, err)
+			assert.Equal(t, key, found)
+
+			_, err = data.GetAPIKeyByHash("unknown")
+			assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+
+			keys, err := data.GetAPIKeys("user1")
+			require.NoError(t, err)
+			assert.Equal(t, []APIKey{key}, keys)
+
+			assert.ErrorIs(t, data.RevokeAPIKey("k1", "user2"), ErrAPIKeyNotFound)
+			assert.ErrorIs(t, data.RevokeAPIKey("unknown", "user1"), ErrAPIKeyNotFound)
+
+			require.NoError(t, data.RevokeAPIKey("k1", "user1"))
+			found, err = data.GetAPIKeyByHash(key.Hash)
+			require.NoError(t, err)
+			require.NotNil(t, found.RevokedAt)
+			revokedAt := *found.RevokedAt
+
+			require.NoError(t, data.RevokeAPIKey("k1", "user1"))
+			found, err = data.GetAPIKeyByHash(key.Hash)
+			require.NoError(t, err)
+			assert.True(t, revokedAt.Equal(*found.RevokedAt))
+
+			found, err = data.GetAPIKeyByHash("other")
+			require.NoError(t, err)
+			assert.Nil(t, found.RevokedAt)
+		})
+	}
+}
+
+func TestAccounts(t *testing.T) {
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeNone),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeNone),
+	}
+
+	account := Account{
+		ID:           "account1",
+		Email:        "vadim@example.com",
+		PasswordHash: "$argon2id$v=19$m=65536,t=3,p=4$c2FsdA$a2V5",
+		CreatedAt:    time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			require.NoError(t, data.AddAccount(account))
+			assert.ErrorIs(t, data.AddAccount(Account{ID: "account2", Email: account.Email, CreatedAt: account.CreatedAt}), ErrEmailTaken)
+
+			found, err := data.GetAccount("account1")
+			require.NoError(t, err)
+			assert.Equal(t, account, found)
+
+			found, err = data.GetAccountByEmail("vadim@example.com")
+			require.NoError(t, err)
+			assert.Equal(t, account, found)
+
+			_, err = data.GetAccount("account2")
+			assert.ErrorIs(t, err, ErrAccountNotFound)
+			_, err = data.GetAccountByEmail("other@example.com")
+			assert.ErrorIs(t, err, ErrAccountNotFound)
+
+			first, err := data.Add("https://filimonovvadim.t.me/1", "anonymous", Options{})
+			require.NoError(t, err)
+			second, err := data.Add("https://filimonovvadim.t.me/2", "anonymous", Options{})
+			require.NoError(t, err)
+			other, err := data.Add("https://filimonovvadim.t.me/3", "other", Options{})
+			require.NoError(t, err)
+
+			claimed, err := data.ClaimItems("anonymous", "account1")
+			require.NoError(t, err)
+			assert.Equal(t, 2, claimed)
+
+			items, err := data.GetItemsOfUser("account1")
+			require.NoError(t, err)
+			shortenURLs := []string{}
+			for _, item := range items {
+				shortenURLs = append(shortenURLs, item.ShortenURL)
+			}
+			assert.ElementsMatch(t, []string{first, second}, shortenURLs)
+
+			items, err = data.GetItemsOfUser("other")
+			require.NoError(t, err)
+			require.Len(t, items, 1)
+			assert.Equal(t, other, items[0].ShortenURL)
+
+			claimed, err = data.ClaimItems("anonymous", "account1")
+			require.NoError(t, err)
+			assert.Zero(t, claimed)
+		})
+	}
+}
+
+func TestConsume(t *testing.T) {
+	backends := map[string]Data{
+		"memory": NewMemory(DedupeGlobal),
+		"file":   NewFile(filepath.Join(t.TempDir(), "urls"), DedupeGlobal),
+	}
+
+	for backend, data := range backends {
+		t.Run(backend, func(t *testing.T) {
+			shortenURL, err := data.Add("https://filimonovvadim.t.me", "user1", Options{OneTime: true})
+			require.NoError(t, err)
+
+			regularURL, err := data.Add("https://filimonovvadim.t.me", "user1", Options{})
+			require.NoError(t, err, "one-time link must not be reused")
+			assert.NotEqual(t, shortenURL, regularURL)
+
+			var wg sync.WaitGroup
+			var mu sync.Mutex
+			consumed := 0
+
+			for i := 0; i < 10; i += 1 {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+
+					err := data.Consume(shortenURL)
+					if err == nil {
+						mu.Lock()
+						consumed += 1
+						mu.Unlock()
+						return
+					}
+					assert.ErrorIs(t, err, ErrURLHasBeenConsumed)
+				}()
+			}
+			wg.Wait()
+
+			assert.Equal(t, 1, consumed)
+
+			_, err = data.Get(shortenURL)
+			assert.ErrorIs(t, err, ErrURLHasBeenConsumed)
+
+			_, err = data.Get(regularURL)
+			assert.NoError(t, err)
+		})
+	}
+}
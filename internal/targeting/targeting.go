@@ -0,0 +1,157 @@
+package targeting
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
+	"github.com/VadimFilimonov/urlshortener/internal/useragent"
+)
+
+// Rule sends visitors matching every set condition to URL. Empty conditions
+// match anyone.
+type Rule struct {
+	// Countries are ISO 3166-1 alpha-2 codes.
+	Countries []string `json:"countries,omitempty"`
+	// Devices and OS are names reported by useragent.Classify.
+	Devices []string `json:"devices,omitempty"`
+	OS      []string `json:"os,omitempty"`
+	// Languages are language tags, "en" matches "en-US" as well.
+	Languages []string `json:"languages,omitempty"`
+	// From and To bound the time window [From, To) the rule is active in.
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+	URL  string     `json:"url"`
+}
+
+// Visitor is what a rule is matched against.
+type Visitor struct {
+	useragent.Info
+	geoip.Location
+	// Languages are taken from the Accept-Language header, see
+	// ParseAcceptLanguage.
+	Languages []string
+	Time      time.Time
+}
+
+func (r Rule) Validate() error {
+	if r.URL == "" {
+		return errors.New("rule has no url")
+	}
+
+	if r.From != nil && r.To != nil && !r.From.Before(*r.To) {
+		return fmt.Errorf("rule for %s: from must be before to", r.URL)
+	}
+
+	return nil
+}
+
+func (r Rule) Matches(visitor Visitor) bool {
+	if r.From != nil && visitor.Time.Before(*r.From) {
+		return false
+	}
+
+	if r.To != nil && !visitor.Time.Before(*r.To) {
+		return false
+	}
+
+	return matchAny(r.Countries, visitor.Country) &&
+		matchAny(r.Devices, visitor.Device) &&
+		matchAny(r.OS, visitor.OS) &&
+		matchLanguage(r.Languages, visitor.Languages)
+}
+
+func matchAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	return slices.IndexFunc(allowed, func(candidate string) bool {
+		return strings.EqualFold(candidate, value)
+	}) >= 0
+}
+
+func matchLanguage(allowed, languages []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+
+	for _, language := range languages {
+		for _, candidate := range allowed {
+			candidate = strings.ToLower(candidate)
+			if language == candidate || strings.HasPrefix(language, candidate+"-") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Rules are evaluated in order, the first matching rule wins.
+type Rules []Rule
+
+// Match returns the URL of the first rule matching visitor.
+func (rules Rules) Match(visitor Visitor) (string, bool) {
+	for _, rule := range rules {
+		if rule.Matches(visitor) {
+			return rule.URL, true
+		}
+	}
+
+	return "", false
+}
+
+// Value stores rules as JSON, no rules as NULL.
+func (rules Rules) Value() (driver.Value, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	return json.Marshal(rules)
+}
+
+func (rules *Rules) Scan(src any) error {
+	switch value := src.(type) {
+	case nil:
+		*rules = nil
+		return nil
+	case []byte:
+		return json.Unmarshal(value, rules)
+	case string:
+		return json.Unmarshal([]byte(value), rules)
+	default:
+		return fmt.Errorf("cannot scan %T into rules", src)
+	}
+}
+
+// ParseAcceptLanguage returns the lowercased language tags of an
+// Accept-Language header in the order they are listed, skipping "*" and
+// tags with q=0.
+func ParseAcceptLanguage(header string) []string {
+	languages := make([]string, 0)
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		params = strings.TrimSpace(params)
+		if strings.HasPrefix(params, "q=") && strings.Trim(params[len("q="):], "0.") == "" {
+			continue
+		}
+
+		languages = append(languages, tag)
+	}
+
+	return languages
+}
@@ -0,0 +1,74 @@
+package targeting
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/VadimFilimonov/urlshortener/internal/geoip"
+	"github.com/VadimFilimonov/urlshortener/internal/useragent"
+)
+
+func TestMatch(t *testing.T) {
+	from := time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+
+	rules := Rules{
+		{OS: []string{"ios"}, Countries: []string{"RU"}, URL: "https://apps.apple.com/ru/app"},
+		{OS: []string{"iOS"}, URL: "https://apps.apple.com/app"},
+		{OS: []string{"Android"}, URL: "https://play.google.com/store/apps"},
+		{Languages: []string{"de"}, URL: "https://example.de"},
+		{From: &from, To: &to, URL: "https://example.com/sale"},
+	}
+
+	tests := []struct {
+		name    string
+		visitor Visitor
+		want    string
+		found   bool
+	}{
+		{
+			name:    "First matching rule wins",
+			visitor: Visitor{Info: useragent.Info{OS: "iOS"}, Location: geoip.Location{Country: "RU"}},
+			want:    "https://apps.apple.com/ru/app",
+			found:   true,
+		},
+		{
+			name:    "Falls through to the next rule",
+			visitor: Visitor{Info: useragent.Info{OS: "iOS"}, Location: geoip.Location{Country: "US"}},
+			want:    "https://apps.apple.com/app",
+			found:   true,
+		},
+		{
+			name:    "Language prefix",
+			visitor: Visitor{Info: useragent.Info{OS: "Windows"}, Languages: []string{"en-us", "de-at"}},
+			want:    "https://example.de",
+			found:   true,
+		},
+		{
+			name:    "Inside time window",
+			visitor: Visitor{Info: useragent.Info{OS: "Windows"}, Time: from.Add(time.Hour)},
+			want:    "https://example.com/sale",
+			found:   true,
+		},
+		{
+			name:    "After time window",
+			visitor: Visitor{Info: useragent.Info{OS: "Windows"}, Time: to},
+			found:   false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found := rules.Match(tt.visitor)
+			assert.Equal(t, tt.found, found)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	assert.Equal(t, []string{"ru-ru", "ru", "en"}, ParseAcceptLanguage("ru-RU,ru;q=0.9, en;q=0.8, *;q=0.5, fr;q=0"))
+	assert.Equal(t, []string{}, ParseAcceptLanguage(""))
+}
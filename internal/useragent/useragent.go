@@ -0,0 +1,92 @@
+package useragent
+
+import (
+	_ "embed"
+	"encoding/json"
+	"regexp"
+	"strings"
+)
+
+// Info is what a User-Agent header tells about a visitor.
+type Info struct {
+	Device  string `json:"device"`
+	OS      string `json:"os"`
+	Browser string `json:"browser"`
+	Bot     bool   `json:"bot"`
+}
+
+type rule struct {
+	Name    string `json:"name"`
+	Match   string `json:"match"`
+	Exclude string `json:"exclude"`
+
+	match   *regexp.Regexp
+	exclude *regexp.Regexp
+}
+
+func (r rule) matches(userAgent string) bool {
+	return r.match.MatchString(userAgent) && (r.exclude == nil || !r.exclude.MatchString(userAgent))
+}
+
+// ruleSet holds ordered rules per category, the first matching rule wins.
+// The last rule of a category has an empty pattern and acts as a default.
+type ruleSet struct {
+	Bots     []rule `json:"bots"`
+	Devices  []rule `json:"devices"`
+	OS       []rule `json:"os"`
+	Browsers []rule `json:"browsers"`
+}
+
+//go:embed rules.json
+var rulesJSON []byte
+
+var rules = mustLoadRules(rulesJSON)
+
+func mustLoadRules(data []byte) ruleSet {
+	var set ruleSet
+
+	err := json.Unmarshal(data, &set)
+	if err != nil {
+		panic(err)
+	}
+
+	for _, category := range [][]rule{set.Bots, set.Devices, set.OS, set.Browsers} {
+		for i := range category {
+			category[i].match = regexp.MustCompile(category[i].Match)
+			if category[i].Exclude != "" {
+				category[i].exclude = regexp.MustCompile(category[i].Exclude)
+			}
+		}
+	}
+
+	return set
+}
+
+func firstMatch(category []rule, userAgent string) string {
+	for _, r := range category {
+		if r.matches(userAgent) {
+			return r.Name
+		}
+	}
+
+	return ""
+}
+
+// Classify parses userAgent with the embedded rule set. An empty User-Agent
+// is counted as a bot, browsers always send one.
+func Classify(userAgent string) Info {
+	lowerUserAgent := strings.ToLower(userAgent)
+
+	info := Info{
+		Device:  firstMatch(rules.Devices, lowerUserAgent),
+		OS:      firstMatch(rules.OS, lowerUserAgent),
+		Browser: firstMatch(rules.Browsers, lowerUserAgent),
+		Bot:     strings.TrimSpace(userAgent) == "" || firstMatch(rules.Bots, lowerUserAgent) != "",
+	}
+
+	if info.Bot {
+		info.Device = "bot"
+	}
+
+	return info
+}
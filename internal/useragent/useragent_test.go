@@ -0,0 +1,57 @@
+package useragent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      Info
+	}{
+		{
+			name:      "Chrome on Windows",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			want:      Info{Device: "desktop", OS: "Windows", Browser: "Chrome"},
+		},
+		{
+			name:      "Safari on iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Mobile/15E148 Safari/604.1",
+			want:      Info{Device: "mobile", OS: "iOS", Browser: "Safari"},
+		},
+		{
+			name:      "Android tablet",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; SM-X200) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36",
+			want:      Info{Device: "tablet", OS: "Android", Browser: "Chrome"},
+		},
+		{
+			name:      "Edge on macOS",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.0.0",
+			want:      Info{Device: "desktop", OS: "macOS", Browser: "Edge"},
+		},
+		{
+			name:      "Search crawler",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:      Info{Device: "bot", OS: "other", Browser: "other", Bot: true},
+		},
+		{
+			name:      "Command line client",
+			userAgent: "curl/8.4.0",
+			want:      Info{Device: "bot", OS: "other", Browser: "other", Bot: true},
+		},
+		{
+			name:      "No User-Agent",
+			userAgent: "",
+			want:      Info{Device: "bot", OS: "other", Browser: "other", Bot: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Classify(tt.userAgent))
+		})
+	}
+}
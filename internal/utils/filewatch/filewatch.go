@@ -0,0 +1,43 @@
+package filewatch
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+// Watch polls filename every interval and calls reload after the file
+// modification time changes. Errors are logged and watching goes on, so a
+// broken file keeps the previously loaded state in use.
+func Watch(filename string, interval time.Duration, reload func() error) {
+	modTime := getModTime(filename)
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			currentModTime := getModTime(filename)
+
+			if currentModTime.Equal(modTime) {
+				continue
+			}
+			modTime = currentModTime
+
+			err := reload()
+			if err != nil {
+				log.Printf("reload %s: %s", filename, err.Error())
+			}
+		}
+	}()
+}
+
+func getModTime(filename string) time.Time {
+	info, err := os.Stat(filename)
+
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -1,20 +1,50 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
 	"math/rand"
 	"time"
+
+	"github.com/VadimFilimonov/urlshortener/internal/blocklist"
 )
 
 const (
 	chars       = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ="
 	charsSize   = len(chars)
 	MaxSizeOfID = 6
+	maxAttempts = 100
 )
 
-func GenerateID() string {
-	ID := ""
+var list = blocklist.New(blocklist.ReservedWords, nil)
+
+// SetBlocklist replaces the list of words generated IDs must not match.
+func SetBlocklist(blocklist *blocklist.Blocklist) {
+	list = blocklist
+}
+
+// ErrNoID is returned when every ID tried was blocked.
+var ErrNoID = errors.New("could not generate an ID that is not blocked")
+
+// GenerateID returns a random ID that passes the blocklist. It gives up
+// with ErrNoID after maxAttempts blocked IDs rather than hand one out.
+func GenerateID() (string, error) {
 	randomGenerator := rand.New(rand.NewSource(time.Now().UnixNano()))
 
+	for i := 0; i < maxAttempts; i += 1 {
+		ID := generate(randomGenerator)
+
+		if list.Check(ID) == nil {
+			return ID, nil
+		}
+	}
+
+	return "", fmt.Errorf("%w after %d attempts", ErrNoID, maxAttempts)
+}
+
+func generate(randomGenerator *rand.Rand) string {
+	ID := ""
+
 	for i := 0; i < MaxSizeOfID; i += 1 {
 		index := randomGenerator.Int63n(int64(charsSize - 1))
 		letter := chars[index]
@@ -1,14 +1,48 @@
 package utils
 
 import (
+	"errors"
+	"strings"
 	"testing"
+
+	"github.com/VadimFilimonov/urlshortener/internal/blocklist"
 )
 
 func TestGenerateID(t *testing.T) {
-	ID := GenerateID()
+	ID, err := GenerateID()
+	if err != nil {
+		t.Fatalf("GenerateID() error = %v", err)
+	}
 	actual := len(ID)
 
 	if actual != MaxSizeOfID {
 		t.Errorf("Generate() = %v, want %v", actual, MaxSizeOfID)
 	}
 }
+
+func TestGenerateIDSkipsBlockedWords(t *testing.T) {
+	SetBlocklist(blocklist.New(blocklist.ReservedWords, []string{"a", "b"}))
+	defer SetBlocklist(blocklist.New(blocklist.ReservedWords, nil))
+
+	for i := 0; i < 100; i += 1 {
+		ID, err := GenerateID()
+		if err != nil {
+			t.Fatalf("GenerateID() error = %v", err)
+		}
+
+		if strings.ContainsAny(strings.ToLower(ID), "ab") {
+			t.Errorf("GenerateID() = %v, contains blocked word", ID)
+		}
+	}
+}
+
+func TestGenerateIDGivesUp(t *testing.T) {
+	SetBlocklist(blocklist.New(nil, strings.Split(strings.ToLower(chars), "")))
+	defer SetBlocklist(blocklist.New(blocklist.ReservedWords, nil))
+
+	ID, err := GenerateID()
+
+	if !errors.Is(err, ErrNoID) {
+		t.Errorf("GenerateID() = %v, %v, want ErrNoID", ID, err)
+	}
+}
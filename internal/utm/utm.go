@@ -0,0 +1,81 @@
+package utm
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+)
+
+// Params are UTM parameters appended to a destination at redirect time.
+type Params struct {
+	Source   string `json:"utm_source,omitempty"`
+	Medium   string `json:"utm_medium,omitempty"`
+	Campaign string `json:"utm_campaign,omitempty"`
+}
+
+func (p Params) IsZero() bool {
+	return p == Params{}
+}
+
+// Merge returns p with empty fields taken from defaults.
+func (p Params) Merge(defaults Params) Params {
+	if p.Source == "" {
+		p.Source = defaults.Source
+	}
+	if p.Medium == "" {
+		p.Medium = defaults.Medium
+	}
+	if p.Campaign == "" {
+		p.Campaign = defaults.Campaign
+	}
+
+	return p
+}
+
+// Apply sets the non-empty parameters on destination, replacing values
+// destination already has.
+func (p Params) Apply(destination string) (string, error) {
+	if p.IsZero() {
+		return destination, nil
+	}
+
+	u, err := url.Parse(destination)
+	if err != nil {
+		return "", err
+	}
+
+	query := u.Query()
+	for key, value := range map[string]string{
+		"utm_source":   p.Source,
+		"utm_medium":   p.Medium,
+		"utm_campaign": p.Campaign,
+	} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// Presets are named campaigns links can refer to instead of spelling out
+// every parameter.
+type Presets map[string]Params
+
+// LoadPresets reads presets from a JSON file of the form
+// {"name": {"utm_source": "...", "utm_medium": "...", "utm_campaign": "..."}}.
+func LoadPresets(filename string) (Presets, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	presets := Presets{}
+	err = json.Unmarshal(data, &presets)
+	if err != nil {
+		return nil, err
+	}
+
+	return presets, nil
+}
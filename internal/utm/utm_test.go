@@ -0,0 +1,50 @@
+package utm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		params      Params
+		destination string
+		want        string
+	}{
+		{
+			name:        "No params",
+			destination: "https://filimonovvadim.t.me/?b=2&a=1",
+			want:        "https://filimonovvadim.t.me/?b=2&a=1",
+		},
+		{
+			name:        "Params are appended",
+			params:      Params{Source: "newsletter", Campaign: "spring sale"},
+			destination: "https://filimonovvadim.t.me/docs?lang=en",
+			want:        "https://filimonovvadim.t.me/docs?lang=en&utm_campaign=spring+sale&utm_source=newsletter",
+		},
+		{
+			name:        "Params replace existing ones",
+			params:      Params{Medium: "email"},
+			destination: "https://filimonovvadim.t.me/?utm_medium=cpc",
+			want:        "https://filimonovvadim.t.me/?utm_medium=email",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.params.Apply(tt.destination)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	preset := Params{Source: "newsletter", Medium: "email", Campaign: "spring"}
+	got := Params{Campaign: "autumn"}.Merge(preset)
+
+	assert.Equal(t, Params{Source: "newsletter", Medium: "email", Campaign: "autumn"}, got)
+}
@@ -0,0 +1,98 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// CanonicalHostname returns hostname the way browsers resolve it: lowercased,
+// without the trailing dot of a fully qualified name and with IPv4 addresses
+// written in dotted decimal. Browsers also accept IPv4 addresses as one
+// number ("2130706433"), in hex or octal ("0x7f.0.0.1") or with fewer parts
+// ("127.1"), so such hosts must not slip past checks of the dotted form.
+// A hostname ending in a number that is no IPv4 address is an error, as
+// browsers refuse it too.
+func CanonicalHostname(hostname string) (string, error) {
+	hostname = strings.TrimSuffix(strings.ToLower(hostname), ".")
+
+	labels := strings.Split(hostname, ".")
+	if !isNumber(labels[len(labels)-1]) {
+		return hostname, nil
+	}
+
+	ip, err := parseIPv4(labels)
+	if err != nil {
+		return "", fmt.Errorf("host %q is not a valid IPv4 address: %w", hostname, err)
+	}
+
+	return ip.String(), nil
+}
+
+// isNumber reports whether label is a decimal or "0x" hex number.
+func isNumber(label string) bool {
+	if label == "" {
+		return false
+	}
+
+	if strings.HasPrefix(label, "0x") {
+		_, err := strconv.ParseUint("0"+label[2:], 16, 64)
+		return err == nil || label == "0x"
+	}
+
+	return strings.Trim(label, "0123456789") == ""
+}
+
+// parseIPv4 parses an IPv4 address of up to four parts in decimal, octal
+// ("017") or hex ("0x1f"). The last part fills the remaining bytes.
+func parseIPv4(parts []string) (net.IP, error) {
+	if len(parts) > 4 {
+		return nil, fmt.Errorf("too many parts")
+	}
+
+	var ip uint64
+
+	for i, part := range parts {
+		value, err := parseIPv4Part(part)
+		if err != nil {
+			return nil, err
+		}
+
+		if i < len(parts)-1 {
+			if value > 255 {
+				return nil, fmt.Errorf("part %q is out of range", part)
+			}
+			ip |= value << (8 * (3 - i))
+			continue
+		}
+
+		if value >= 1<<(8*(5-len(parts))) {
+			return nil, fmt.Errorf("part %q is out of range", part)
+		}
+		ip |= value
+	}
+
+	return net.IPv4(byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip)), nil
+}
+
+func parseIPv4Part(part string) (uint64, error) {
+	base := 10
+
+	switch {
+	case strings.HasPrefix(part, "0x"):
+		part, base = part[2:], 16
+		if part == "" {
+			return 0, nil
+		}
+	case len(part) > 1 && strings.HasPrefix(part, "0"):
+		part, base = part[1:], 8
+	}
+
+	value, err := strconv.ParseUint(part, base, 32)
+	if err != nil {
+		return 0, fmt.Errorf("malformed part %q", part)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,136 @@
+package validation
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ReasonEmpty     = "empty_url"
+	ReasonMalformed = "malformed_url"
+	ReasonNotAbs    = "not_absolute_url"
+	ReasonScheme    = "scheme_not_allowed"
+	ReasonOwnHost   = "redirect_loop"
+	ReasonTooLong   = "url_too_long"
+)
+
+// MaxURLLength is the length of the columns URLs are stored in.
+const MaxURLLength = 255
+
+var DefaultSchemes = []string{"http", "https"}
+
+var defaultPorts = map[string]string{
+	"http":  "80",
+	"https": "443",
+	"ftp":   "21",
+}
+
+// Error describes why a URL was rejected. Reason is a stable machine
+// readable code, Message is meant for humans.
+type Error struct {
+	Reason  string
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+type Validator struct {
+	schemes map[string]struct{}
+	ownHost string
+}
+
+// New returns a Validator accepting URLs with one of schemes and rejecting
+// URLs which point back to baseURL.
+func New(baseURL string, schemes []string) *Validator {
+	v := &Validator{
+		schemes: map[string]struct{}{},
+	}
+
+	for _, scheme := range schemes {
+		v.schemes[strings.ToLower(strings.TrimSpace(scheme))] = struct{}{}
+	}
+
+	base, err := url.Parse(baseURL)
+	if err == nil {
+		v.ownHost, _ = normalizeHost(strings.ToLower(base.Scheme), base.Host)
+	}
+
+	return v
+}
+
+// Normalize validates rawURL and returns its canonical form: scheme and
+// host are lowercased, the host is made canonical with CanonicalHostname,
+// the default port for the scheme is dropped and a
+// bare "/" path is removed.
+func (v *Validator) Normalize(rawURL string) (string, error) {
+	rawURL = strings.TrimSpace(rawURL)
+
+	if rawURL == "" {
+		return "", &Error{Reason: ReasonEmpty, Message: "url is empty"}
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", &Error{Reason: ReasonMalformed, Message: err.Error()}
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+
+	if _, ok := v.schemes[u.Scheme]; !ok {
+		return "", &Error{Reason: ReasonScheme, Message: "scheme \"" + u.Scheme + "\" is not allowed"}
+	}
+
+	if u.Host == "" || u.Opaque != "" {
+		return "", &Error{Reason: ReasonNotAbs, Message: "url must be absolute and contain a host"}
+	}
+
+	u.Host, err = normalizeHost(u.Scheme, u.Host)
+	if err != nil {
+		return "", &Error{Reason: ReasonMalformed, Message: err.Error()}
+	}
+
+	if v.ownHost != "" && u.Host == v.ownHost {
+		return "", &Error{Reason: ReasonOwnHost, Message: "url points to the shortener itself"}
+	}
+
+	if u.Path == "/" && u.RawQuery == "" && u.Fragment == "" {
+		u.Path = ""
+	}
+
+	normalized := u.String()
+	if utf8.RuneCountInString(normalized) > MaxURLLength {
+		return "", &Error{Reason: ReasonTooLong, Message: fmt.Sprintf("url must not be longer than %d characters", MaxURLLength)}
+	}
+
+	return normalized, nil
+}
+
+func normalizeHost(scheme, host string) (string, error) {
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, ""
+	}
+
+	if !strings.HasPrefix(hostname, "[") && !strings.Contains(hostname, ":") {
+		hostname, err = CanonicalHostname(hostname)
+		if err != nil {
+			return "", err
+		}
+	}
+	hostname = strings.ToLower(hostname)
+
+	if strings.Contains(hostname, ":") && !strings.HasPrefix(hostname, "[") {
+		hostname = "[" + hostname + "]"
+	}
+
+	if port == "" || defaultPorts[scheme] == port {
+		return hostname, nil
+	}
+
+	return hostname + ":" + port, nil
+}
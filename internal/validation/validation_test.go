@@ -0,0 +1,102 @@
+package validation
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalize(t *testing.T) {
+	v := New("http://localhost:8080", DefaultSchemes)
+
+	tests := []struct {
+		name   string
+		url    string
+		want   string
+		reason string
+	}{
+		{
+			name: "Valid url",
+			url:  "https://filimonovvadim.t.me/path?q=1",
+			want: "https://filimonovvadim.t.me/path?q=1",
+		},
+		{
+			name: "Host case, default port and root slash",
+			url:  " HTTPS://Example.COM:443/ ",
+			want: "https://example.com",
+		},
+		{
+			name: "Non-default port is kept",
+			url:  "http://example.com:8081/a/",
+			want: "http://example.com:8081/a/",
+		},
+		{
+			name: "Trailing dot of the host",
+			url:  "http://Example.com./x",
+			want: "http://example.com/x",
+		},
+		{
+			name: "IPv4 address in browser forms",
+			url:  "http://0x7f.1:8080/x",
+			want: "http://127.0.0.1:8080/x",
+		},
+		{
+			name: "IPv6 address",
+			url:  "http://[::1]:80/x",
+			want: "http://[::1]/x",
+		},
+		{
+			name:   "Host ending in a number that is no IPv4 address",
+			url:    "http://example.123/",
+			reason: ReasonMalformed,
+		},
+		{
+			name:   "Too long url",
+			url:    "https://example.com/" + strings.Repeat("a", MaxURLLength),
+			reason: ReasonTooLong,
+		},
+		{
+			name:   "Empty url",
+			url:    "",
+			reason: ReasonEmpty,
+		},
+		{
+			name:   "Not a url",
+			url:    "filimonovvadim",
+			reason: ReasonScheme,
+		},
+		{
+			name:   "Javascript scheme",
+			url:    "javascript:alert(1)",
+			reason: ReasonScheme,
+		},
+		{
+			name:   "Missing host",
+			url:    "http:///path",
+			reason: ReasonNotAbs,
+		},
+		{
+			name:   "Own host",
+			url:    "http://LOCALHOST:8080/abcdef",
+			reason: ReasonOwnHost,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := v.Normalize(tt.url)
+
+			if tt.reason == "" {
+				require.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+				return
+			}
+
+			var validationErr *Error
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, tt.reason, validationErr.Reason)
+		})
+	}
+}
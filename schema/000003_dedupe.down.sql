@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS urls_original_url_idx;
+ALTER TABLE urls ADD CONSTRAINT urls_original_url_key UNIQUE (original_url);
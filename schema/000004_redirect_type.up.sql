@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN redirect_type integer not null default 0;
@@ -0,0 +1,2 @@
+ALTER TABLE urls ADD COLUMN pass_query boolean not null default false;
+ALTER TABLE urls ADD COLUMN pass_path boolean not null default false;
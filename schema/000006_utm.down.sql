@@ -0,0 +1,3 @@
+ALTER TABLE urls DROP COLUMN utm_campaign;
+ALTER TABLE urls DROP COLUMN utm_medium;
+ALTER TABLE urls DROP COLUMN utm_source;
@@ -0,0 +1,3 @@
+ALTER TABLE urls ADD COLUMN utm_source varchar(255) not null default '';
+ALTER TABLE urls ADD COLUMN utm_medium varchar(255) not null default '';
+ALTER TABLE urls ADD COLUMN utm_campaign varchar(255) not null default '';
@@ -0,0 +1,8 @@
+CREATE TABLE url_clicks
+(
+  shorten_url varchar(255) not null,
+  day date not null,
+  clicks integer not null,
+  last_click timestamptz not null,
+  primary key (shorten_url, day)
+);
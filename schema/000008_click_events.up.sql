@@ -0,0 +1,11 @@
+CREATE TABLE clicks
+(
+  id bigserial primary key,
+  shorten_url varchar(255) not null,
+  clicked_at timestamptz not null,
+  referer text not null default '',
+  user_agent text not null default '',
+  ip varchar(64) not null default '',
+  accept_language text not null default ''
+);
+CREATE INDEX clicks_shorten_url_clicked_at_idx ON clicks (shorten_url, clicked_at);
@@ -0,0 +1,8 @@
+ALTER TABLE clicks DROP COLUMN bot;
+ALTER TABLE clicks DROP COLUMN browser;
+ALTER TABLE clicks DROP COLUMN os;
+ALTER TABLE clicks DROP COLUMN device;
+DELETE FROM url_clicks WHERE bot;
+ALTER TABLE url_clicks DROP CONSTRAINT url_clicks_pkey;
+ALTER TABLE url_clicks ADD PRIMARY KEY (shorten_url, day);
+ALTER TABLE url_clicks DROP COLUMN bot;
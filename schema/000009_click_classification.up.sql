@@ -0,0 +1,7 @@
+ALTER TABLE url_clicks ADD COLUMN bot boolean not null default false;
+ALTER TABLE url_clicks DROP CONSTRAINT url_clicks_pkey;
+ALTER TABLE url_clicks ADD PRIMARY KEY (shorten_url, day, bot);
+ALTER TABLE clicks ADD COLUMN device varchar(32) not null default '';
+ALTER TABLE clicks ADD COLUMN os varchar(32) not null default '';
+ALTER TABLE clicks ADD COLUMN browser varchar(32) not null default '';
+ALTER TABLE clicks ADD COLUMN bot boolean not null default false;
@@ -0,0 +1,2 @@
+ALTER TABLE clicks DROP COLUMN region;
+ALTER TABLE clicks DROP COLUMN country;
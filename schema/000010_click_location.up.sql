@@ -0,0 +1,2 @@
+ALTER TABLE clicks ADD COLUMN country varchar(2) not null default '';
+ALTER TABLE clicks ADD COLUMN region varchar(128) not null default '';
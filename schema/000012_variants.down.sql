@@ -0,0 +1,3 @@
+ALTER TABLE clicks DROP COLUMN variant;
+ALTER TABLE urls DROP COLUMN sticky_variants;
+ALTER TABLE urls DROP COLUMN variants;
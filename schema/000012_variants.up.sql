@@ -0,0 +1,3 @@
+ALTER TABLE urls ADD COLUMN variants jsonb;
+ALTER TABLE urls ADD COLUMN sticky_variants boolean not null default false;
+ALTER TABLE clicks ADD COLUMN variant varchar(64) not null default '';
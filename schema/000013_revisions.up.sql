@@ -0,0 +1,9 @@
+CREATE TABLE url_revisions
+(
+  shorten_url varchar(255) not null,
+  revision integer not null,
+  original_url varchar(255) not null,
+  options jsonb not null,
+  replaced_at timestamptz not null,
+  primary key (shorten_url, revision)
+);
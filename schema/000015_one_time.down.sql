@@ -0,0 +1 @@
+ALTER TABLE urls DROP COLUMN one_time;
@@ -0,0 +1 @@
+ALTER TABLE urls ADD COLUMN one_time boolean not null default false;
@@ -0,0 +1,2 @@
+ALTER TABLE urls DROP COLUMN fallback_url;
+ALTER TABLE urls DROP COLUMN activates_at;
@@ -0,0 +1,2 @@
+ALTER TABLE urls ADD COLUMN activates_at timestamptz;
+ALTER TABLE urls ADD COLUMN fallback_url varchar(255) not null default '';
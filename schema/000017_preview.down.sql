@@ -0,0 +1,3 @@
+ALTER TABLE urls DROP COLUMN force_preview;
+ALTER TABLE urls DROP COLUMN title;
+ALTER TABLE urls DROP COLUMN created_at;
@@ -0,0 +1,3 @@
+ALTER TABLE urls ADD COLUMN created_at timestamptz;
+ALTER TABLE urls ADD COLUMN title varchar(255) not null default '';
+ALTER TABLE urls ADD COLUMN force_preview boolean not null default false;
@@ -0,0 +1,7 @@
+ALTER TABLE urls ADD COLUMN tags text[] not null default '{}';
+ALTER TABLE urls ADD COLUMN folder varchar(255) not null default '';
+ALTER TABLE urls ADD COLUMN search tsvector;
+UPDATE urls SET search = to_tsvector('simple', regexp_replace(lower(original_url || ' ' || title), '[^[:alnum:]]+', ' ', 'g'));
+CREATE INDEX urls_tags_idx ON urls USING gin (tags);
+CREATE INDEX urls_user_id_folder_idx ON urls (user_id, folder);
+CREATE INDEX urls_search_idx ON urls USING gin (search);
@@ -0,0 +1,12 @@
+CREATE TABLE api_keys
+(
+  id varchar(32) primary key,
+  user_id varchar(255) not null,
+  name varchar(255) not null,
+  prefix varchar(16) not null,
+  hash char(64) not null unique,
+  scopes text[] not null,
+  created_at timestamptz not null,
+  revoked_at timestamptz
+);
+CREATE INDEX api_keys_user_id_idx ON api_keys (user_id);
@@ -0,0 +1,7 @@
+CREATE TABLE accounts
+(
+  id varchar(32) primary key,
+  email varchar(254) not null unique,
+  password_hash varchar(255) not null,
+  created_at timestamptz not null
+);
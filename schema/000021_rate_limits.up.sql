@@ -0,0 +1,7 @@
+CREATE TABLE rate_limits
+(
+  key varchar(512) primary key,
+  tokens double precision not null,
+  updated_at timestamptz not null
+);
+CREATE INDEX rate_limits_updated_at_idx ON rate_limits (updated_at);
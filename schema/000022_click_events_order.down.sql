@@ -0,0 +1 @@
+DROP INDEX clicks_shorten_url_id_idx;
@@ -0,0 +1 @@
+CREATE INDEX clicks_shorten_url_id_idx ON clicks (shorten_url, id);